@@ -0,0 +1,661 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/rpc"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// erros das operações de administração
+var (
+	ErrBanido               = errors.New("você está banido deste servidor")
+	ErrJogadorNaoEncontrado = errors.New("nenhum jogador com esse nome, id ou ip")
+	ErrPosicaoBloqueada     = errors.New("posição bloqueada ou fora do mapa")
+)
+
+// um jogador visto pela administração
+type InfoJogador struct {
+	ID       string
+	Nome     string
+	PosX     int
+	PosY     int
+	Endereco string // ip:porta da conexão do jogador
+}
+
+// pedidos das RPCs de administração (todos levam o token do AutenticarAdmin)
+type AdminAlvoRequest struct {
+	Token string
+	Alvo  string // nome, id ou ip
+}
+
+type AdminTeleporteRequest struct {
+	Token string
+	Alvo  string
+	X, Y  int
+}
+
+type AdminAvisoRequest struct {
+	Token    string
+	Mensagem string // vazio apaga o aviso
+}
+
+type AdminMapaRequest struct {
+	Token string
+	Mapa  []string // linhas do mapa novo
+}
+
+type AdminDesligarRequest struct {
+	Token  string
+	Motivo string        // mostrado aos jogadores
+	Espera time.Duration // contagem regressiva antes de fechar as conexões
+}
+
+type AdminJogadoresResponse struct {
+	Jogadores []InfoJogador
+}
+
+// resposta do ObterMapa: mapa atual do servidor
+type ObterMapaResponse struct {
+	Mapa       []string
+	VersaoMapa int64
+}
+
+// Administracao são as operações do console de administração. O próprio
+// GameServer implementa (console no terminal do servidor) e o AdminRemoto
+// também (console num outro terminal, pelas RPCs).
+type Administracao interface {
+	Status() (AdminStatusResponse, error)
+	Jogadores() ([]InfoJogador, error)
+	Expulsar(alvo string) (int, error)
+	Banir(alvo string) (int, error)
+	Desbanir(alvo string) error
+	Banidos() ([]string, error)
+	Teleportar(alvo string, x, y int) error
+	Anunciar(mensagem string) error
+	TrocarMapa(linhas []string) error
+	AgendarDesligamento(motivo string, espera time.Duration) error
+}
+
+// nomes e ips banidos (só em memória)
+type listaBanidos struct {
+	mutex sync.Mutex
+	itens map[string]bool
+}
+
+func novaListaBanidos() *listaBanidos {
+	return &listaBanidos{itens: make(map[string]bool)}
+}
+
+func (l *listaBanidos) adicionar(alvo string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.itens[alvo] = true
+}
+
+func (l *listaBanidos) remover(alvo string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	existe := l.itens[alvo]
+	delete(l.itens, alvo)
+	return existe
+}
+
+func (l *listaBanidos) contem(alvo string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.itens[alvo]
+}
+
+func (l *listaBanidos) listar() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	lista := make([]string, 0, len(l.itens))
+	for alvo := range l.itens {
+		lista = append(lista, alvo)
+	}
+	sort.Strings(lista)
+	return lista
+}
+
+// diz se o alvo digitado pelo admin é esse jogador (por id, nome ou ip)
+func alvoConfere(alvo, id, nome, endereco string) bool {
+	return alvo == id || alvo == nome || alvo == ipDoEndereco(endereco)
+}
+
+// ---- implementação local (roda no processo do servidor) ----
+
+// Resumo do servidor
+func (gs *GameServer) Status() (AdminStatusResponse, error) {
+	snap := gs.snapshot.Load()
+	return AdminStatusResponse{
+		Jogadores:    len(snap.jogadores),
+		Espectadores: gs.contarEspectadores(),
+		Tick:         snap.tick,
+		Ativo:        time.Since(gs.inicio).Round(time.Second),
+	}, nil
+}
+
+// Lista os jogadores conectados, com posição e endereço
+func (gs *GameServer) Jogadores() ([]InfoJogador, error) {
+	enderecos := make(map[string]string)
+	gs.conexoes.Range(func(chave, _ any) bool {
+		conexao := chave.(*conexaoCliente)
+		for _, id := range conexao.idsJogadores() {
+			enderecos[id] = conexao.endereco
+		}
+		return true
+	})
+
+	snap := gs.snapshot.Load()
+	lista := make([]InfoJogador, 0, len(snap.jogadores))
+	for id, jogador := range snap.jogadores {
+		lista = append(lista, InfoJogador{ID: id, Nome: jogador.Nome, PosX: jogador.PosX, PosY: jogador.PosY, Endereco: enderecos[id]})
+	}
+	sort.Slice(lista, func(i, j int) bool { return lista[i].Nome < lista[j].Nome })
+	return lista, nil
+}
+
+// Derruba as conexões dos jogadores que batem com o alvo; devolve quantos saíram
+func (gs *GameServer) Expulsar(alvo string) (int, error) {
+	snap := gs.snapshot.Load()
+	expulsos := 0
+	gs.conexoes.Range(func(chave, _ any) bool {
+		conexao := chave.(*conexaoCliente)
+		for _, id := range conexao.idsJogadores() {
+			if alvoConfere(alvo, id, snap.jogadores[id].Nome, conexao.endereco) {
+				// a conexão cai inteira: os outros jogadores dela saem junto
+				expulsos += gs.expulsar(conexao, id, "expulso pela administração")
+				break
+			}
+		}
+		return true
+	})
+	if expulsos == 0 {
+		return 0, ErrJogadorNaoEncontrado
+	}
+	return expulsos, nil
+}
+
+// Bane o nome ou ip e expulsa quem estiver conectado com ele
+func (gs *GameServer) Banir(alvo string) (int, error) {
+	gs.banidos.adicionar(alvo)
+	log.Printf("[admin] %s banido", alvo)
+	expulsos, _ := gs.Expulsar(alvo) // banir quem não está online também vale
+	return expulsos, nil
+}
+
+// Tira o nome ou ip da lista de banidos
+func (gs *GameServer) Desbanir(alvo string) error {
+	if !gs.banidos.remover(alvo) {
+		return fmt.Errorf("%s não estava banido", alvo)
+	}
+	log.Printf("[admin] %s desbanido", alvo)
+	return nil
+}
+
+// Nomes e ips banidos
+func (gs *GameServer) Banidos() ([]string, error) {
+	return gs.banidos.listar(), nil
+}
+
+// Move o jogador pra (x, y), descartando os movimentos que ele tinha na fila
+func (gs *GameServer) Teleportar(alvo string, x, y int) error {
+	err := ErrJogadorNaoEncontrado
+	gs.executar(func() {
+		for id, jogador := range gs.jogadores {
+			if alvo != id && alvo != jogador.Nome {
+				continue
+			}
+			if !gs.podeOcupar(x, y, id) {
+				err = ErrPosicaoBloqueada
+				return
+			}
+			gs.reposicionar(id, Ponto{x, y})
+			log.Printf("[admin] %s teleportado pra (%d, %d)", jogador.Nome, x, y)
+			err = nil
+			return
+		}
+	})
+	return err
+}
+
+// Mostra uma mensagem na linha de aviso de todos os clientes (vazio apaga)
+func (gs *GameServer) Anunciar(mensagem string) error {
+	gs.executar(func() {
+		gs.anunciar(mensagem)
+	})
+	log.Printf("[admin] aviso: %q", mensagem)
+	return nil
+}
+
+// Troca o mapa sem reiniciar: os clientes baixam o novo e os jogadores vão pros spawns
+func (gs *GameServer) TrocarMapa(linhas []string) error {
+	jogo := &Jogo{}
+	if err := CarregarMapaDeLinhas(linhas, jogo); err != nil {
+		return err
+	}
+
+	err := ErrServidorDesligado // fica assim se o loop parou antes de trocar
+	gs.executar(func() {
+		// ordem fixa pra distribuir os spawns sempre do mesmo jeito
+		ids := make([]string, 0, len(gs.jogadores))
+		for id := range gs.jogadores {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		// antes de mexer em qualquer coisa, confere se todo mundo cabe no mapa novo:
+		// põe os jogadores um de cada vez num mundo vazio com o mapa novo
+		jogadores, elementos := gs.jogadores, gs.elementos
+		gs.jogadores, gs.elementos = make(map[string]PosicaoJogador, len(ids)), jogo.Mapa
+		destinos := make(map[string]Ponto, len(ids))
+		semLugar := 0
+		for i, id := range ids {
+			jogador := jogadores[id]
+			preferida := Ponto{jogador.PosX, jogador.PosY}
+			if len(jogo.Spawns) > 0 {
+				preferida = jogo.Spawns[i%len(jogo.Spawns)]
+			}
+			destino, cabe := gs.posicaoLivre(preferida, id)
+			if !cabe {
+				semLugar++
+				continue
+			}
+			jogador.PosX, jogador.PosY = destino.X, destino.Y
+			gs.jogadores[id] = jogador
+			destinos[id] = destino
+		}
+		gs.jogadores, gs.elementos = jogadores, elementos
+		if semLugar > 0 {
+			err = fmt.Errorf("%w: %d dos %d jogadores não cabem no mapa novo, o mapa não foi trocado", ErrMapaCheio, semLugar, len(ids))
+			return
+		}
+
+		gs.mapa = linhas
+		gs.elementos = jogo.Mapa
+		gs.spawns = jogo.Spawns
+		gs.versaoMapa++
+		gs.gravar(EventoReplay{Tipo: EventoMapa, Mapa: linhas})
+		for _, id := range ids {
+			gs.reposicionar(id, destinos[id])
+		}
+		err = nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("[admin] mapa trocado (%d linhas)", len(linhas))
+	return nil
+}
+
+// coloca o jogador na posição e avisa o cliente pelo Reposicao (só chamado dentro do loop)
+func (gs *GameServer) reposicionar(id string, p Ponto) {
+	jogador := gs.jogadores[id]
+	jogador.PosX, jogador.PosY = p.X, p.Y
+	jogador.Reposicao++
+	gs.jogadores[id] = jogador
+	gs.gravar(EventoReplay{Tipo: EventoReposicionar, Jogador: id, X: p.X, Y: p.Y})
+	gs.descartarFila(id, ErrAcaoDescartada) // os movimentos pendentes eram relativos à posição antiga
+}
+
+// A posição preferida se estiver livre, senão a primeira livre do mapa; false quando
+// não sobrou nenhuma célula sem parede nem jogador (só chamado dentro do loop)
+func (gs *GameServer) posicaoLivre(preferida Ponto, id string) (Ponto, bool) {
+	if gs.podeOcupar(preferida.X, preferida.Y, id) {
+		return preferida, true
+	}
+	if gs.elementos == nil {
+		// sem mapa não tem parede: anda pro lado até sair de cima dos outros jogadores
+		for x := preferida.X + 1; ; x++ {
+			if gs.podeOcupar(x, preferida.Y, id) {
+				return Ponto{x, preferida.Y}, true
+			}
+		}
+	}
+	for y, linha := range gs.elementos {
+		for x := range linha {
+			if gs.podeOcupar(x, y, id) {
+				return Ponto{x, y}, true
+			}
+		}
+	}
+	return preferida, false
+}
+
+// ---- RPCs ----
+
+// RPC: Mapa atual do servidor (o cliente pede quando a VersaoMapa muda)
+func (gs *GameService) ObterMapa(req SessaoRequest, reply *ObterMapaResponse) error {
+	if err := gs.verificarSessao(req.JogadorID, req.Token, "ObterMapa"); err != nil {
+		return err
+	}
+	snap := gs.servidor.snapshot.Load()
+	reply.Mapa = snap.mapa
+	reply.VersaoMapa = snap.versaoMapa
+	return nil
+}
+
+// RPC (admin): Lista os jogadores conectados
+func (gs *GameService) AdminJogadores(req AdminRequest, reply *AdminJogadoresResponse) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+	var err error
+	reply.Jogadores, err = gs.servidor.Jogadores()
+	return err
+}
+
+// RPC (admin): Expulsa jogadores por nome, id ou ip
+func (gs *GameService) AdminExpulsar(req AdminAlvoRequest, reply *int) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+	var err error
+	*reply, err = gs.servidor.Expulsar(req.Alvo)
+	return err
+}
+
+// RPC (admin): Bane um nome ou ip
+func (gs *GameService) AdminBanir(req AdminAlvoRequest, reply *int) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+	var err error
+	*reply, err = gs.servidor.Banir(req.Alvo)
+	return err
+}
+
+// RPC (admin): Tira um nome ou ip da lista de banidos
+func (gs *GameService) AdminDesbanir(req AdminAlvoRequest, reply *bool) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+	err := gs.servidor.Desbanir(req.Alvo)
+	*reply = err == nil // false quando deu erro
+	return err
+}
+
+// RPC (admin): Lista os banidos
+func (gs *GameService) AdminBanidos(req AdminRequest, reply *[]string) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+	var err error
+	*reply, err = gs.servidor.Banidos()
+	return err
+}
+
+// RPC (admin): Teleporta um jogador
+func (gs *GameService) AdminTeleportar(req AdminTeleporteRequest, reply *bool) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+	err := gs.servidor.Teleportar(req.Alvo, req.X, req.Y)
+	*reply = err == nil
+	return err
+}
+
+// RPC (admin): Manda um aviso pra todos
+func (gs *GameService) AdminAnunciar(req AdminAvisoRequest, reply *bool) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+	err := gs.servidor.Anunciar(req.Mensagem)
+	*reply = err == nil
+	return err
+}
+
+// RPC (admin): Troca o mapa do jogo
+func (gs *GameService) AdminTrocarMapa(req AdminMapaRequest, reply *bool) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+	err := gs.servidor.TrocarMapa(req.Mapa)
+	*reply = err == nil
+	return err
+}
+
+// RPC (admin): Começa o desligamento do servidor
+func (gs *GameService) AdminDesligar(req AdminDesligarRequest, reply *bool) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+	err := gs.servidor.AgendarDesligamento(req.Motivo, req.Espera)
+	*reply = err == nil
+	return err
+}
+
+// ---- implementação remota (console em outro terminal) ----
+
+// AdminRemoto faz as operações de administração pelas RPCs
+type AdminRemoto struct {
+	client *rpc.Client
+	token  string
+}
+
+// Conecta no servidor e faz login de administrador
+func ConectarAdmin(config NetworkConfig, senha string) (*AdminRemoto, error) {
+	conn, err := config.Dial()
+	if err != nil {
+		return nil, err
+	}
+	client := rpc.NewClient(conn)
+	if _, err := fazerHandshake(client, "console de administração"); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	var resp AdminLoginResponse
+	if err := client.Call("GameService.AutenticarAdmin", AdminLoginRequest{Senha: senha}, &resp); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &AdminRemoto{client: client, token: resp.Token}, nil
+}
+
+func (a *AdminRemoto) Close() error {
+	return a.client.Close()
+}
+
+func (a *AdminRemoto) Status() (AdminStatusResponse, error) {
+	var resp AdminStatusResponse
+	err := a.client.Call("GameService.AdminStatus", AdminRequest{a.token}, &resp)
+	return resp, err
+}
+
+func (a *AdminRemoto) Jogadores() ([]InfoJogador, error) {
+	var resp AdminJogadoresResponse
+	err := a.client.Call("GameService.AdminJogadores", AdminRequest{a.token}, &resp)
+	return resp.Jogadores, err
+}
+
+func (a *AdminRemoto) Expulsar(alvo string) (int, error) {
+	var n int
+	err := a.client.Call("GameService.AdminExpulsar", AdminAlvoRequest{a.token, alvo}, &n)
+	return n, err
+}
+
+func (a *AdminRemoto) Banir(alvo string) (int, error) {
+	var n int
+	err := a.client.Call("GameService.AdminBanir", AdminAlvoRequest{a.token, alvo}, &n)
+	return n, err
+}
+
+func (a *AdminRemoto) Desbanir(alvo string) error {
+	var ok bool
+	return a.client.Call("GameService.AdminDesbanir", AdminAlvoRequest{a.token, alvo}, &ok)
+}
+
+func (a *AdminRemoto) Banidos() ([]string, error) {
+	var lista []string
+	err := a.client.Call("GameService.AdminBanidos", AdminRequest{a.token}, &lista)
+	return lista, err
+}
+
+func (a *AdminRemoto) Teleportar(alvo string, x, y int) error {
+	var ok bool
+	return a.client.Call("GameService.AdminTeleportar", AdminTeleporteRequest{a.token, alvo, x, y}, &ok)
+}
+
+func (a *AdminRemoto) Anunciar(mensagem string) error {
+	var ok bool
+	return a.client.Call("GameService.AdminAnunciar", AdminAvisoRequest{a.token, mensagem}, &ok)
+}
+
+func (a *AdminRemoto) TrocarMapa(linhas []string) error {
+	var ok bool
+	return a.client.Call("GameService.AdminTrocarMapa", AdminMapaRequest{a.token, linhas}, &ok)
+}
+
+func (a *AdminRemoto) AgendarDesligamento(motivo string, espera time.Duration) error {
+	var ok bool
+	return a.client.Call("GameService.AdminDesligar", AdminDesligarRequest{a.token, motivo, espera}, &ok)
+}
+
+// ---- console ----
+
+const ajudaConsole = `comandos:
+  status                 resumo do servidor
+  jogadores              lista jogadores com id, posição e endereço
+  kick <alvo>            expulsa por nome, id ou ip
+  ban <alvo>             bane um nome ou ip (e expulsa quem estiver online)
+  unban <alvo>           tira da lista de banidos
+  bans                   lista os banidos
+  tp <alvo> <x> <y>      teleporta um jogador
+  msg [texto]            aviso na tela de todos (sem texto apaga)
+  mapa <arquivo>         troca o mapa sem reiniciar
+  desligar [seg] [motivo] desliga o servidor avisando os jogadores (padrão 5s)
+  ajuda                  mostra isso`
+
+// RodarConsoleAdmin lê comandos linha por linha até a entrada acabar
+func RodarConsoleAdmin(adm Administracao, entrada io.Reader, saida io.Writer) {
+	scanner := bufio.NewScanner(entrada)
+	for scanner.Scan() {
+		linha := strings.TrimSpace(scanner.Text())
+		if linha == "" {
+			continue
+		}
+		if linha == "sair" {
+			return
+		}
+		if err := executarComandoAdmin(adm, linha, saida); err != nil {
+			fmt.Fprintln(saida, "erro:", err)
+		}
+	}
+}
+
+// interpreta uma linha do console
+func executarComandoAdmin(adm Administracao, linha string, saida io.Writer) error {
+	comando, resto, _ := strings.Cut(linha, " ")
+	resto = strings.TrimSpace(resto)
+
+	// comandos com alvo precisam dele (nomes podem ter espaço, então é o resto da linha)
+	switch comando {
+	case "kick", "ban", "unban":
+		if resto == "" {
+			return fmt.Errorf("uso: %s <alvo>", comando)
+		}
+	case "mapa":
+		if resto == "" {
+			return errors.New("uso: mapa <arquivo>")
+		}
+	}
+
+	switch comando {
+	case "ajuda", "help":
+		fmt.Fprintln(saida, ajudaConsole)
+	case "status":
+		st, err := adm.Status()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(saida, "%d jogadores, %d espectadores, tick %d, no ar há %v\n", st.Jogadores, st.Espectadores, st.Tick, st.Ativo)
+	case "jogadores":
+		lista, err := adm.Jogadores()
+		if err != nil {
+			return err
+		}
+		for _, j := range lista {
+			fmt.Fprintf(saida, "%-16s %-36s (%3d, %3d) %s\n", j.Nome, j.ID, j.PosX, j.PosY, j.Endereco)
+		}
+		fmt.Fprintf(saida, "%d jogadores\n", len(lista))
+	case "kick":
+		n, err := adm.Expulsar(resto)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(saida, "%d jogadores expulsos\n", n)
+	case "ban":
+		n, err := adm.Banir(resto)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(saida, "%s banido (%d jogadores expulsos)\n", resto, n)
+	case "unban":
+		if err := adm.Desbanir(resto); err != nil {
+			return err
+		}
+		fmt.Fprintf(saida, "%s desbanido\n", resto)
+	case "bans":
+		lista, err := adm.Banidos()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(saida, "%d banidos: %s\n", len(lista), strings.Join(lista, ", "))
+	case "tp":
+		campos := strings.Fields(resto)
+		if len(campos) < 3 {
+			return errors.New("uso: tp <alvo> <x> <y>")
+		}
+		x, errX := strconv.Atoi(campos[len(campos)-2])
+		y, errY := strconv.Atoi(campos[len(campos)-1])
+		if errX != nil || errY != nil {
+			return errors.New("x e y precisam ser números")
+		}
+		alvo := strings.Join(campos[:len(campos)-2], " ")
+		if err := adm.Teleportar(alvo, x, y); err != nil {
+			return err
+		}
+		fmt.Fprintf(saida, "%s teleportado pra (%d, %d)\n", alvo, x, y)
+	case "msg":
+		if err := adm.Anunciar(resto); err != nil {
+			return err
+		}
+		fmt.Fprintln(saida, "aviso enviado")
+	case "mapa":
+		linhas, err := LerLinhasMapa(resto)
+		if err != nil {
+			return err
+		}
+		if err := adm.TrocarMapa(linhas); err != nil {
+			return err
+		}
+		fmt.Fprintf(saida, "mapa %s carregado\n", resto)
+	case "desligar":
+		espera := ConfigServidorPadrao.AvisoDesligamento
+		motivo := "servidor desligado pela administração"
+		campos := strings.SplitN(resto, " ", 2)
+		if segundos, err := strconv.Atoi(campos[0]); err == nil {
+			espera = time.Duration(segundos) * time.Second
+			campos = campos[1:]
+		}
+		if len(campos) > 0 && strings.TrimSpace(campos[0]) != "" {
+			motivo = strings.TrimSpace(campos[0])
+		}
+		if err := adm.AgendarDesligamento(motivo, espera); err != nil {
+			return err
+		}
+		fmt.Fprintf(saida, "desligando em %v: %s\n", espera, motivo)
+	default:
+		return fmt.Errorf("comando desconhecido %q (digite ajuda)", comando)
+	}
+	return nil
+}
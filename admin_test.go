@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpulsarTiraTodosOsJogadoresDaConexao(t *testing.T) {
+	gs := servidorDeTeste(t, ConfigServidorPadrao)
+	dupla := servicoDeTeste(t, gs)
+	sozinho := servicoDeTeste(t, gs)
+
+	entrarDeTeste(t, dupla, "Ana")
+	entrarDeTeste(t, dupla, "Bia")
+	caio := entrarDeTeste(t, sozinho, "Caio")
+
+	// o alvo é só a Ana, mas a conexão cai inteira e a Bia sai junto
+	expulsos, err := gs.Expulsar("Ana")
+	if err != nil {
+		t.Fatalf("erro ao expulsar: %v", err)
+	}
+	if expulsos != 2 {
+		t.Fatalf("Expulsar devolveu %d, esperava 2", expulsos)
+	}
+
+	jogadores := gs.snapshot.Load().jogadores
+	if len(jogadores) != 1 || jogadores[caio.JogadorID].Nome != "Caio" {
+		t.Fatalf("depois da expulsão esperava só o Caio no jogo, tem %v", jogadores)
+	}
+	if ids := dupla.conexao.idsJogadores(); len(ids) != 0 {
+		t.Fatalf("a conexão expulsa ainda tem jogadores: %v", ids)
+	}
+
+	// de novo não tem mais ninguém pra expulsar
+	if expulsos, err := gs.Expulsar("Ana"); !errors.Is(err, ErrJogadorNaoEncontrado) || expulsos != 0 {
+		t.Fatalf("Expulsar de novo = %d, %v; esperava 0, ErrJogadorNaoEncontrado", expulsos, err)
+	}
+}
+
+func TestAdminDesbanirRespondeFalseNoErro(t *testing.T) {
+	gs := servidorDeTeste(t, ConfigServidorPadrao)
+	admin := servicoDeTeste(t, gs)
+	admin.conexao.tokenAdmin = "token"
+
+	var ok bool
+	if err := admin.AdminDesbanir(AdminAlvoRequest{Token: "token", Alvo: "ninguem"}, &ok); err == nil || ok {
+		t.Fatalf("desbanir quem não estava banido = %v, %v; esperava erro e false", ok, err)
+	}
+
+	gs.Banir("Ana")
+	if err := admin.AdminDesbanir(AdminAlvoRequest{Token: "token", Alvo: "Ana"}, &ok); err != nil || !ok {
+		t.Fatalf("desbanir a Ana = %v, %v; esperava true, sem erro", ok, err)
+	}
+}
+
+func TestTrocarMapa(t *testing.T) {
+	casos := []struct {
+		nome  string
+		mapa  []string
+		cabem bool
+	}{
+		{nome: "cabem todos", mapa: []string{"▤▤▤▤▤", "▤   ▤", "▤▤▤▤▤"}, cabem: true},
+		{nome: "cabem todos, um em cada spawn", mapa: []string{"▤▤▤▤▤", "▤☺☺☺▤", "▤▤▤▤▤"}, cabem: true},
+		{nome: "mapa pequeno demais", mapa: []string{"▤▤▤▤", "▤  ▤", "▤▤▤▤"}},
+		{nome: "um spawn só", mapa: []string{"▤▤▤", "▤☺▤", "▤▤▤"}},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			gs := servidorDeTeste(t, ConfigServidorPadrao)
+			servico := servicoDeTeste(t, gs)
+			for _, nome := range []string{"Ana", "Bia", "Caio"} {
+				entrarDeTeste(t, servico, nome)
+			}
+			antes := gs.snapshot.Load()
+
+			err := gs.TrocarMapa(c.mapa)
+			depois := gs.snapshot.Load()
+			if !c.cabem {
+				if !errors.Is(err, ErrMapaCheio) {
+					t.Fatalf("TrocarMapa = %v, esperava ErrMapaCheio", err)
+				}
+				// nada mudou: nem o mapa nem ninguém de lugar
+				if depois.versaoMapa != antes.versaoMapa || len(depois.mapa) != len(antes.mapa) {
+					t.Fatalf("o mapa foi trocado mesmo sem caber todo mundo")
+				}
+				for id, jogador := range antes.jogadores {
+					if d := depois.jogadores[id]; d.PosX != jogador.PosX || d.PosY != jogador.PosY || d.Reposicao != jogador.Reposicao {
+						t.Errorf("%s mudou de (%d, %d) pra (%d, %d)", jogador.Nome, jogador.PosX, jogador.PosY, d.PosX, d.PosY)
+					}
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("TrocarMapa = %v", err)
+			}
+			if depois.versaoMapa != antes.versaoMapa+1 {
+				t.Fatalf("versão do mapa %d, esperava %d", depois.versaoMapa, antes.versaoMapa+1)
+			}
+			elementos := mapaDeTeste(t, c.mapa...)
+			ocupadas := make(map[Ponto]string)
+			for _, jogador := range depois.jogadores {
+				p := Ponto{jogador.PosX, jogador.PosY}
+				if p.Y < 0 || p.Y >= len(elementos) || p.X < 0 || p.X >= len(elementos[p.Y]) || elementos[p.Y][p.X].Tangivel {
+					t.Errorf("%s foi parar em %v, fora do mapa ou na parede", jogador.Nome, p)
+				}
+				if outro, existe := ocupadas[p]; existe {
+					t.Errorf("%s e %s foram parar na mesma célula %v", jogador.Nome, outro, p)
+				}
+				ocupadas[p] = jogador.Nome
+			}
+		})
+	}
+}
+
+func TestTrocarMapaComOLoopParado(t *testing.T) {
+	gs, err := NewGameServerWithConfig(ConfigServidorPadrao)
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(gs.parar)
+	<-gs.parado
+
+	if err := gs.TrocarMapa([]string{"▤▤▤", "▤ ▤", "▤▤▤"}); !errors.Is(err, ErrServidorDesligado) {
+		t.Fatalf("TrocarMapa = %v, esperava ErrServidorDesligado", err)
+	}
+}
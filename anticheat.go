@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// erros devolvidos aos clientes quando uma regra é quebrada
+var (
+	ErrJogadorDeOutraConexao = errors.New("jogador não pertence a esta conexão")
+	ErrSessaoInvalida        = errors.New("sessão inválida")
+	ErrLimiteMovimento       = errors.New("movimento acima do limite de velocidade")
+	ErrExpulso               = errors.New("jogador expulso por comportamento suspeito")
+)
+
+// uma conexão TCP de cliente e os jogadores que ela criou
+type conexaoCliente struct {
+	conn       net.Conn
+	endereco   string
+	mutex      sync.Mutex
+	jogadores  map[string]*controleJogador // jogadorID -> controle de velocidade/infrações
+	expulsa    bool                        // a conexão foi derrubada pelo servidor
+	tokenAdmin string                      // token de administrador desta conexão (vazio = não logou)
+	versao     int                         // versão do protocolo combinada no handshake (0 = não fez)
+}
+
+// estado do anti-cheat de um jogador
+type controleJogador struct {
+	token      string    // segredo da sessão entregue no ConectarJogo
+	fichas     float64   // movimentos disponíveis agora (balde de fichas)
+	atualizado time.Time // quando as fichas foram recalculadas pela última vez
+	ultimaSeq  int64     // maior sequence number recebido
+	infracoes  int       // quantas vezes quebrou alguma regra
+	espectador bool      // sessão de espectador: pode assistir, mas não jogar
+}
+
+func novaConexaoCliente(conn net.Conn) *conexaoCliente {
+	return &conexaoCliente{
+		conn:      conn,
+		endereco:  conn.RemoteAddr().String(),
+		jogadores: make(map[string]*controleJogador),
+	}
+}
+
+// guarda a versão do protocolo combinada no handshake
+func (c *conexaoCliente) definirVersao(versao int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.versao = versao
+}
+
+// versão do protocolo desta conexão (0 = ainda não fez o handshake)
+func (c *conexaoCliente) versaoProtocolo() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.versao
+}
+
+// associa um jogador recém-criado e o token da sessão a esta conexão
+func (c *conexaoCliente) adicionarJogador(id, token string, rajada int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.jogadores[id] = &controleJogador{token: token, fichas: float64(rajada), atualizado: time.Now()}
+}
+
+// associa um espectador e o token da sessão a esta conexão
+func (c *conexaoCliente) adicionarEspectador(id, token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.jogadores[id] = &controleJogador{token: token, espectador: true}
+}
+
+// ids dos jogadores criados por esta conexão
+func (c *conexaoCliente) idsJogadores() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ids := make([]string, 0, len(c.jogadores))
+	for id := range c.jogadores {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// esquece um jogador (desconectou ou foi removido)
+func (c *conexaoCliente) removerJogador(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.jogadores, id)
+}
+
+// devolve o controle do jogador se ele foi criado por esta conexão
+func (c *conexaoCliente) controle(id string) (*controleJogador, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ctrl, ok := c.jogadores[id]
+	return ctrl, ok
+}
+
+// confere se o jogador pertence à conexão e se o token da sessão bate
+func (gs *GameService) verificarSessao(jogadorID, token, rpc string) error {
+	ctrl, ok := gs.conexao.controle(jogadorID)
+	if !ok {
+		log.Printf("[anticheat] %s chamou %s com um jogador de outra conexão", gs.conexao.endereco, rpc)
+		return ErrJogadorDeOutraConexao
+	}
+	if subtle.ConstantTimeCompare([]byte(ctrl.token), []byte(token)) != 1 {
+		log.Printf("[anticheat] %s chamou %s com token inválido", gs.conexao.endereco, rpc)
+		return ErrSessaoInvalida
+	}
+	return nil
+}
+
+// gera um token aleatório impossível de adivinhar
+func gerarToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// valida o movimento contra o limite de velocidade e os saltos de sequência
+func (gs *GameService) validarMovimento(req MoverRequest) error {
+	if err := gs.verificarSessao(req.JogadorID, req.Token, "Mover"); err != nil {
+		return err
+	}
+	return gs.limitarAcao(req.JogadorID, req.SequenceNumber, 1)
+}
+
+// regras de toda ação de jogador, depois da sessão conferida: espectador não age e
+// movimentos e comandos dividem o limite de velocidade e a sequência. Um lote conta
+// como n ações de uma vez: ou cabe inteiro no limite ou é recusado inteiro.
+func (gs *GameService) limitarAcao(jogadorID string, seq int64, n int) error {
+	cfg := gs.servidor.config
+
+	// procura de novo com a trava: um Desconectar ou uma expulsão nesta mesma
+	// conexão pode ter tirado o jogador depois do verificarSessao
+	gs.conexao.mutex.Lock()
+	ctrl, ok := gs.conexao.jogadores[jogadorID]
+	switch {
+	case !ok:
+		gs.conexao.mutex.Unlock()
+		return ErrSessaoInvalida
+	case ctrl.espectador:
+		gs.conexao.mutex.Unlock()
+		return ErrEspectador
+	}
+	agora := time.Now()
+	ctrl.fichas = min(float64(cfg.RajadaMovimentos),
+		ctrl.fichas+agora.Sub(ctrl.atualizado).Seconds()*float64(cfg.MovimentosPorSegundo))
+	ctrl.atualizado = agora
+
+	var motivo string
+	var err error
+	switch {
+	case ctrl.fichas < float64(n):
+		motivo, err = "passou do limite de movimentos por segundo", ErrLimiteMovimento
+	case ctrl.ultimaSeq > 0 && seq-ctrl.ultimaSeq > cfg.SaltoMaximoSequencia:
+		// não bloqueia (pode ser perda de pacotes), só anota
+		motivo = "pulou muitos sequence numbers"
+	}
+	if err == nil {
+		ctrl.fichas -= float64(n)
+	}
+	ctrl.ultimaSeq = max(ctrl.ultimaSeq, seq)
+	if motivo != "" {
+		ctrl.infracoes++
+	}
+	infracoes := ctrl.infracoes
+	gs.conexao.mutex.Unlock()
+
+	if motivo == "" {
+		return nil
+	}
+
+	// loga só a primeira e depois de tempos em tempos, pra não encher o log
+	if infracoes == 1 || infracoes%50 == 0 {
+		log.Printf("[anticheat] jogador %s (%s) %s (seq %d, %d infrações)",
+			jogadorID, gs.conexao.endereco, motivo, seq, infracoes)
+	}
+	if cfg.ExpulsarInfratores && infracoes >= cfg.LimiteInfracoes {
+		gs.servidor.expulsar(gs.conexao, jogadorID, motivo)
+		return ErrExpulso
+	}
+	return err
+}
+
+// Derruba a conexão e tira do jogo todos os jogadores (e espectadores) dela;
+// devolve quantos saíram (0 se a conexão já tinha sido derrubada)
+func (gs *GameServer) expulsar(conexao *conexaoCliente, jogadorID, motivo string) int {
+	conexao.mutex.Lock()
+	if conexao.expulsa {
+		conexao.mutex.Unlock()
+		return 0
+	}
+	conexao.expulsa = true
+	conexao.mutex.Unlock()
+
+	log.Printf("Expulsando %s (%s): %s", jogadorID, conexao.endereco, motivo)
+	ids := conexao.idsJogadores()
+	removidos := 0
+	gs.executar(func() {
+		for _, id := range ids {
+			if _, existe := gs.jogadores[id]; existe {
+				gs.removerJogador(id)
+				removidos++
+			}
+		}
+	})
+	for _, id := range ids {
+		if gs.removerEspectador(id) {
+			removidos++
+		}
+		conexao.removerJogador(id)
+	}
+	conexao.conn.Close()
+	return removidos
+}
+
+// verifica no mapa do servidor se a célula pode ser ocupada (só chamado dentro do loop)
+func (gs *GameServer) podeOcupar(x, y int, jogadorID string) bool {
+	if gs.elementos != nil {
+		if y < 0 || y >= len(gs.elementos) || x < 0 || x >= len(gs.elementos[y]) {
+			return false
+		}
+		if gs.elementos[y][x].Tangivel {
+			return false
+		}
+	}
+	for id, outro := range gs.jogadores {
+		if id != jogadorID && outro.Conectado && outro.PosX == x && outro.PosY == y {
+			return false
+		}
+	}
+	return true
+}
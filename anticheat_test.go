@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLimitarAcao(t *testing.T) {
+	casos := []struct {
+		nome       string
+		config     func(cfg *ConfigServidor)
+		espectador bool
+		antes      func(ctrl *controleJogador)
+		seq        int64
+		n          int
+		erro       error
+		fichas     float64 // fichas que sobram depois
+		infracoes  int
+	}{
+		{nome: "uma ação cabe", seq: 1, n: 1, fichas: 4},
+		{nome: "lote do tamanho da rajada", seq: 5, n: 5, fichas: 0},
+		{nome: "lote maior que as fichas é recusado inteiro", seq: 6, n: 6, erro: ErrLimiteMovimento, fichas: 5, infracoes: 1},
+		{
+			nome:   "sem fichas",
+			antes:  func(ctrl *controleJogador) { ctrl.fichas = 0.5 },
+			seq:    1,
+			n:      1,
+			erro:   ErrLimiteMovimento,
+			fichas: 0.5, infracoes: 1,
+		},
+		{
+			nome:   "fichas voltam com o tempo",
+			antes:  func(ctrl *controleJogador) { ctrl.fichas, ctrl.atualizado = 0, time.Now().Add(-2*time.Second) },
+			seq:    1,
+			n:      2,
+			fichas: 0,
+		},
+		{
+			nome:   "a recarga não passa da rajada",
+			antes:  func(ctrl *controleJogador) { ctrl.fichas, ctrl.atualizado = 0, time.Now().Add(-time.Hour) },
+			seq:    1,
+			n:      6,
+			erro:   ErrLimiteMovimento,
+			fichas: 5, infracoes: 1,
+		},
+		{
+			nome:   "salto de sequência só anota infração",
+			antes:  func(ctrl *controleJogador) { ctrl.ultimaSeq = 1 },
+			seq:    500,
+			n:      1,
+			fichas: 4, infracoes: 1,
+		},
+		{nome: "espectador não age", espectador: true, seq: 1, n: 1, erro: ErrEspectador},
+		{
+			nome:   "expulsa no limite de infrações",
+			config: func(cfg *ConfigServidor) { cfg.ExpulsarInfratores, cfg.LimiteInfracoes = true, 2 },
+			antes:  func(ctrl *controleJogador) { ctrl.fichas, ctrl.infracoes = 0, 1 },
+			seq:    1,
+			n:      1,
+			erro:   ErrExpulso,
+			fichas: 0, infracoes: 2,
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			cfg := ConfigServidorPadrao
+			cfg.RajadaMovimentos, cfg.MovimentosPorSegundo, cfg.SaltoMaximoSequencia = 5, 1, 100
+			if c.config != nil {
+				c.config(&cfg)
+			}
+			servico := servicoDeTeste(t, servidorDeTeste(t, cfg))
+			if c.espectador {
+				servico.conexao.adicionarEspectador("j", "token")
+			} else {
+				servico.conexao.adicionarJogador("j", "token", cfg.RajadaMovimentos)
+			}
+			ctrl, _ := servico.conexao.controle("j")
+			if c.antes != nil {
+				c.antes(ctrl)
+			}
+
+			err := servico.limitarAcao("j", c.seq, c.n)
+			if !errors.Is(err, c.erro) {
+				t.Fatalf("limitarAcao = %v, esperava %v", err, c.erro)
+			}
+			if c.espectador {
+				return
+			}
+			if math.Abs(ctrl.fichas-c.fichas) > 0.01 {
+				t.Errorf("sobraram %.2f fichas, esperava %.2f", ctrl.fichas, c.fichas)
+			}
+			if ctrl.infracoes != c.infracoes {
+				t.Errorf("%d infrações, esperava %d", ctrl.infracoes, c.infracoes)
+			}
+			if ctrl.ultimaSeq != c.seq {
+				t.Errorf("ultimaSeq = %d, esperava %d", ctrl.ultimaSeq, c.seq)
+			}
+		})
+	}
+}
+
+func TestLimitarAcaoSemSessao(t *testing.T) {
+	servico := servicoDeTeste(t, servidorDeTeste(t, ConfigServidorPadrao))
+	if err := servico.limitarAcao("desconhecido", 1, 1); !errors.Is(err, ErrSessaoInvalida) {
+		t.Fatalf("limitarAcao com um id desconhecido = %v, esperava ErrSessaoInvalida", err)
+	}
+
+	// o Desconectar (ou uma expulsão) desta conexão tirou o jogador depois do verificarSessao
+	servico.conexao.adicionarJogador("j", "token", ConfigServidorPadrao.RajadaMovimentos)
+	servico.conexao.removerJogador("j")
+	if err := servico.limitarAcao("j", 1, 1); !errors.Is(err, ErrSessaoInvalida) {
+		t.Fatalf("limitarAcao com um jogador recém-removido = %v, esperava ErrSessaoInvalida", err)
+	}
+}
+
+// várias RPCs da mesma conexão rodam ao mesmo tempo: o jogador pode sair no meio de um movimento
+func TestLimitarAcaoComDesconexaoAoMesmoTempo(t *testing.T) {
+	servico := servicoDeTeste(t, servidorDeTeste(t, ConfigServidorPadrao))
+	for i := range 200 {
+		servico.conexao.adicionarJogador("j", "token", ConfigServidorPadrao.RajadaMovimentos)
+		feito := make(chan error)
+		go func() { feito <- servico.limitarAcao("j", int64(i+1), 1) }()
+		servico.conexao.removerJogador("j")
+		if err := <-feito; err != nil && !errors.Is(err, ErrSessaoInvalida) && !errors.Is(err, ErrLimiteMovimento) {
+			t.Fatalf("limitarAcao = %v", err)
+		}
+	}
+}
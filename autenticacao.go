@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// erros de autenticação devolvidos aos clientes
+var (
+	ErrSenhaIncorreta   = errors.New("senha do servidor incorreta")
+	ErrSenhaAdmin       = errors.New("senha de administrador incorreta")
+	ErrAdminDesativado  = errors.New("este servidor não tem administração remota")
+	ErrNaoAutorizado    = errors.New("comando exige login de administrador")
+	ErrMuitasTentativas = errors.New("muitas tentativas com senha errada, espere um pouco")
+)
+
+// pedido de login de administrador
+type AdminLoginRequest struct {
+	Senha string
+}
+
+// token de administrador, exigido em todas as RPCs de administração
+type AdminLoginResponse struct {
+	Token string
+}
+
+// identifica o administrador numa RPC de administração
+type AdminRequest struct {
+	Token string
+}
+
+// resumo do servidor pra quem administra
+type AdminStatusResponse struct {
+	Jogadores    int           // jogadores conectados
+	Espectadores int           // conexões só assistindo
+	Tick         int64         // tick atual da simulação
+	Ativo        time.Duration // há quanto tempo o servidor está no ar
+}
+
+// conta as senhas erradas de cada ip pra bloquear tentativa e erro
+type limitadorTentativas struct {
+	mutex    sync.Mutex
+	maximo   int           // falhas permitidas dentro da janela
+	janela   time.Duration // tempo da janela e do bloqueio
+	falhas   map[string][]time.Time
+	bloqueio map[string]time.Time // ip -> até quando está bloqueado
+}
+
+func novoLimitadorTentativas(maximo int, janela time.Duration) *limitadorTentativas {
+	return &limitadorTentativas{
+		maximo:   maximo,
+		janela:   janela,
+		falhas:   make(map[string][]time.Time),
+		bloqueio: make(map[string]time.Time),
+	}
+}
+
+// diz se o ip ainda pode tentar uma senha
+func (l *limitadorTentativas) permitido(ip string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return time.Now().After(l.bloqueio[ip])
+}
+
+// anota uma senha errada; devolve true se o ip acabou de ser bloqueado
+func (l *limitadorTentativas) falhou(ip string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	agora := time.Now()
+	recentes := l.falhas[ip][:0]
+	for _, t := range l.falhas[ip] {
+		if agora.Sub(t) < l.janela {
+			recentes = append(recentes, t)
+		}
+	}
+	recentes = append(recentes, agora)
+	l.falhas[ip] = recentes
+
+	if len(recentes) >= l.maximo {
+		l.bloqueio[ip] = agora.Add(l.janela)
+		delete(l.falhas, ip)
+		return true
+	}
+	return false
+}
+
+// esquece as falhas de um ip depois de uma senha certa
+func (l *limitadorTentativas) acertou(ip string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.falhas, ip)
+}
+
+// compara senhas sem vazar informação pelo tempo de resposta
+func senhaConfere(esperada, recebida string) bool {
+	return subtle.ConstantTimeCompare([]byte(esperada), []byte(recebida)) == 1
+}
+
+// só o ip, sem a porta (todas as conexões da mesma máquina contam juntas)
+func ipDoEndereco(endereco string) string {
+	host, _, err := net.SplitHostPort(endereco)
+	if err != nil {
+		return endereco
+	}
+	return host
+}
+
+// confere uma senha aplicando o limite de tentativas do ip da conexão
+func (gs *GameService) conferirSenha(esperada, recebida string, errSenha error, oque string) error {
+	ip := ipDoEndereco(gs.conexao.endereco)
+	limitador := gs.servidor.tentativas
+	if !limitador.permitido(ip) {
+		return ErrMuitasTentativas
+	}
+	if senhaConfere(esperada, recebida) {
+		limitador.acertou(ip)
+		return nil
+	}
+
+	if limitador.falhou(ip) {
+		log.Printf("[auth] %s bloqueado por %v depois de errar a senha %s várias vezes", ip, limitador.janela, oque)
+	} else {
+		log.Printf("[auth] %s errou a senha %s", ip, oque)
+	}
+	return errSenha
+}
+
+// RPC: Faz login de administrador nesta conexão
+func (gs *GameService) AutenticarAdmin(req AdminLoginRequest, reply *AdminLoginResponse) error {
+	if err := gs.exigirHandshake(); err != nil {
+		return err
+	}
+	if gs.servidor.config.SenhaAdmin == "" {
+		return ErrAdminDesativado
+	}
+	if err := gs.conferirSenha(gs.servidor.config.SenhaAdmin, req.Senha, ErrSenhaAdmin, "de administrador"); err != nil {
+		return err
+	}
+
+	token := gerarToken()
+	gs.conexao.mutex.Lock()
+	gs.conexao.tokenAdmin = token
+	gs.conexao.mutex.Unlock()
+
+	log.Printf("[auth] administrador autenticado de %s", gs.conexao.endereco)
+	reply.Token = token
+	return nil
+}
+
+// confere se a conexão fez login de administrador com esse token
+func (gs *GameService) verificarAdmin(token string) error {
+	gs.conexao.mutex.Lock()
+	esperado := gs.conexao.tokenAdmin
+	gs.conexao.mutex.Unlock()
+
+	if esperado == "" || !senhaConfere(esperado, token) {
+		return ErrNaoAutorizado
+	}
+	return nil
+}
+
+// RPC (admin): Resumo do estado do servidor
+func (gs *GameService) AdminStatus(req AdminRequest, reply *AdminStatusResponse) error {
+	if err := gs.verificarAdmin(req.Token); err != nil {
+		return err
+	}
+
+	var err error
+	*reply, err = gs.servidor.Status()
+	return err
+}
+
+// traduz erros de conexão e do servidor numa mensagem clara pro jogador
+func MensagemErro(err error, config NetworkConfig) string {
+	var opErr *net.OpError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return fmt.Sprintf("Não foi possível conectar em %s. O servidor está rodando?", config.GetAddress())
+	}
+
+	// erros que vêm do servidor chegam só como texto (rpc.ServerError)
+	switch err.Error() {
+	case ErrSenhaIncorreta.Error():
+		return "Senha incorreta. Use -senha com a senha do servidor."
+	case ErrMuitasTentativas.Error():
+		return "Senha errada muitas vezes seguidas. Espere um pouco antes de tentar de novo."
+	case ErrSenhaAdmin.Error():
+		return "Senha de administrador incorreta."
+	case ErrAdminDesativado.Error():
+		return "Este servidor não aceita administração remota."
+	case ErrServidorDesligando.Error():
+		return "O servidor está desligando. Tente de novo daqui a pouco."
+	case ErrSegredoIncorreto.Error():
+		return "Segredo do perfil incorreto. Esse nome já tem dono: use o segredo certo ou outro -nome."
+	case ErrPerfilEmUso.Error():
+		return "Esse perfil já está jogando em outra conexão."
+	case ErrMapaCheio.Error():
+		return "O mapa do servidor está lotado. Tente de novo quando alguém sair."
+	case ErrBanido.Error():
+		return "Você foi banido deste servidor."
+	case ErrServidorAntigo.Error():
+		return "O servidor é de uma versão antiga deste jogo. Atualize o servidor ou use um cliente da mesma versão."
+	case ErrSemHandshake.Error():
+		return "O servidor não reconheceu a versão deste cliente. Atualize o cliente."
+	case ErrSemComandos.Error():
+		return "O servidor é de uma versão antiga: não tem chat, emotes nem ataque."
+	case ErrNadaParaInteragir.Error():
+		return "Não tem nenhum inimigo do lado pra atacar."
+	}
+	if strings.HasPrefix(err.Error(), ErrProtocoloIncompativel.Error()) {
+		return "Versão incompatível com o servidor (" + strings.TrimPrefix(err.Error(), ErrProtocoloIncompativel.Error()+": ") + ")."
+	}
+	return "Erro: " + err.Error()
+}
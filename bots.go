@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// comportamento dos bots
+const (
+	intervaloBot       = 250 * time.Millisecond // tempo médio entre movimentos (~4 por segundo)
+	variacaoBot        = 150 * time.Millisecond // variação aleatória pra não andarem sincronizados
+	raioPerseguicao    = 15                     // distância até onde um bot vê e persegue outro jogador
+	intervaloRelatorio = 10 * time.Second       // frequência do resumo no log
+)
+
+// contadores compartilhados por todos os bots
+type estatisticasBots struct {
+	movimentos atomic.Int64
+	erros      atomic.Int64
+	conectados atomic.Int64
+}
+
+// um jogador controlado pelo computador, usando o GameClient normal sem interface
+type Bot struct {
+	nome    string
+	cliente *GameClient
+	rng     *rand.Rand
+	rota    []Ponto // passos restantes até o destino atual de passeio
+}
+
+// Conecta um bot ao servidor
+func NovoBot(nome string, config NetworkConfig, mapaFile string, seed int64) (*Bot, error) {
+	cliente, err := NewGameClientWithConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cliente.ConectarJogoComNome(mapaFile, nome); err != nil {
+		cliente.Close()
+		return nil, err
+	}
+	return &Bot{nome: nome, cliente: cliente, rng: rand.New(rand.NewSource(seed))}, nil
+}
+
+// roda o bot até o canal parar ser fechado
+func (b *Bot) Rodar(parar <-chan struct{}, stats *estatisticasBots) {
+	for {
+		espera := intervaloBot + time.Duration(b.rng.Int63n(int64(2*variacaoBot))) - variacaoBot
+		select {
+		case <-parar:
+			return
+		case <-b.cliente.Caiu():
+			log.Printf("%s saiu: %s", b.nome, b.cliente.MotivoQueda())
+			stats.conectados.Add(-1)
+			return
+		case <-time.After(espera):
+		}
+
+		if err := b.cliente.ObterPosicoes(); err != nil {
+			stats.erros.Add(1)
+			continue
+		}
+		tecla, ok := b.escolherMovimento()
+		if !ok {
+			continue
+		}
+		if err := b.cliente.Mover(b.cliente.jogadorID, tecla); err != nil {
+			stats.erros.Add(1)
+			continue
+		}
+		stats.movimentos.Add(1)
+	}
+}
+
+// persegue o jogador mais próximo se houver um por perto, senão passeia pelo mapa
+func (b *Bot) escolherMovimento() (rune, bool) {
+	gm := b.cliente.GetGameManager()
+	origem, ok := gm.PosicaoLocal()
+	if !ok {
+		return 0, false
+	}
+	estado := gm.ObterEstado()
+	ocupadas := PosicoesOcupadas(estado.Jogadores, b.cliente.jogadorID)
+	custo := EvitandoOcupadas(CustoPadrao(estado.Mapa), ocupadas)
+
+	// persegue: caminho até o vizinho do jogador mais próximo
+	var melhor []Ponto
+	for id, jogador := range estado.Jogadores {
+		alvo := Ponto{jogador.PosX, jogador.PosY}
+		if id == b.cliente.jogadorID || abs(alvo.X-origem.X)+abs(alvo.Y-origem.Y) > raioPerseguicao {
+			continue
+		}
+		delete(ocupadas, alvo)
+		caminho := BuscarCaminho(estado.Mapa, origem, alvo, custo)
+		ocupadas[alvo] = true
+		if len(caminho) > 1 && (melhor == nil || len(caminho) < len(melhor)) {
+			melhor = caminho
+		}
+	}
+	if melhor != nil {
+		b.rota = nil // depois da perseguição escolhe um passeio novo
+		return TeclaPara(origem, melhor[0])
+	}
+
+	// passeio: segue a rota atual ou sorteia um destino alcançável
+	if len(b.rota) == 0 || !gm.PodeMoverLocal(b.rota[0]) {
+		b.rota = b.sortearRota(estado.Mapa, origem, custo)
+	}
+	if len(b.rota) == 0 {
+		// sem destino: tenta uma direção qualquer
+		return []rune{'w', 'a', 's', 'd'}[b.rng.Intn(4)], true
+	}
+	proximo := b.rota[0]
+	b.rota = b.rota[1:]
+	return TeclaPara(origem, proximo)
+}
+
+// sorteia uma célula livre próxima e calcula o caminho até ela
+func (b *Bot) sortearRota(mapa [][]Elemento, origem Ponto, custo FuncaoCusto) []Ponto {
+	largura, altura := dimensoesMapa(mapa)
+	for tentativa := 0; tentativa < 20; tentativa++ {
+		destino := Ponto{
+			X: max(0, min(largura-1, origem.X+b.rng.Intn(2*raioPerseguicao+1)-raioPerseguicao)),
+			Y: max(0, min(altura-1, origem.Y+b.rng.Intn(2*raioPerseguicao+1)-raioPerseguicao)),
+		}
+		if caminho := BuscarCaminho(mapa, origem, destino, custo); len(caminho) > 0 {
+			return caminho
+		}
+	}
+	return nil
+}
+
+// Inicia N bots e mantém eles jogando até o processo receber Ctrl+C
+func runBots(n int, config NetworkConfig, mapaFile string) {
+	stats := &estatisticasBots{}
+	parar := make(chan struct{})
+	var wg sync.WaitGroup
+	var bots []*Bot
+
+	for i := 1; i <= n; i++ {
+		bot, err := NovoBot(fmt.Sprintf("Bot%02d", i), config, mapaFile, time.Now().UnixNano()+int64(i))
+		if err != nil {
+			log.Printf("Bot %d não conseguiu entrar: %s", i, MensagemErro(err, config))
+			continue
+		}
+		bots = append(bots, bot)
+		stats.conectados.Add(1)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bot.Rodar(parar, stats)
+		}()
+	}
+	log.Printf("%d de %d bots conectados em %s", len(bots), n, config.GetAddress())
+
+	sinais := make(chan os.Signal, 1)
+	signal.Notify(sinais, os.Interrupt)
+	relatorio := time.NewTicker(intervaloRelatorio)
+	defer relatorio.Stop()
+
+	for rodando := true; rodando; {
+		select {
+		case <-sinais:
+			rodando = false
+		case <-relatorio.C:
+			log.Printf("Bots: %d conectados, %d movimentos, %d erros",
+				stats.conectados.Load(), stats.movimentos.Load(), stats.erros.Load())
+		}
+	}
+
+	log.Println("Desconectando bots...")
+	close(parar)
+	wg.Wait()
+	for _, bot := range bots {
+		bot.cliente.Close()
+	}
+}
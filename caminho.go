@@ -0,0 +1,254 @@
+package main
+
+import "container/heap"
+
+// diz se dá pra entrar na célula (x, y) e quanto custa entrar nela
+type FuncaoCusto func(x, y int) (custo int, passavel bool)
+
+// deslocamentos nas quatro direções em que os jogadores andam
+var direcoesCaminho = [4]Ponto{{0, -1}, {-1, 0}, {0, 1}, {1, 0}}
+
+// custo padrão: elementos tangíveis bloqueiam, o resto custa 1
+func CustoPadrao(mapa [][]Elemento) FuncaoCusto {
+	return func(x, y int) (int, bool) {
+		if y < 0 || y >= len(mapa) || x < 0 || x >= len(mapa[y]) {
+			return 0, false
+		}
+		if mapa[y][x].Tangivel {
+			return 0, false
+		}
+		return 1, true
+	}
+}
+
+// soma um custo extra pra passar por vegetação (ex: pra preferir o caminho limpo)
+func ComCustoVegetacao(base FuncaoCusto, mapa [][]Elemento, extra int) FuncaoCusto {
+	return func(x, y int) (int, bool) {
+		custo, ok := base(x, y)
+		if ok && mapa[y][x].Simbolo == Vegetacao.Simbolo {
+			custo += extra
+		}
+		return custo, ok
+	}
+}
+
+// trata as células ocupadas como bloqueadas
+func EvitandoOcupadas(base FuncaoCusto, ocupadas map[Ponto]bool) FuncaoCusto {
+	return func(x, y int) (int, bool) {
+		if ocupadas[Ponto{x, y}] {
+			return 0, false
+		}
+		return base(x, y)
+	}
+}
+
+// posições dos jogadores conectados, exceto o informado (pra usar com EvitandoOcupadas)
+func PosicoesOcupadas(jogadores map[string]*Jogador, exceto string) map[Ponto]bool {
+	ocupadas := make(map[Ponto]bool, len(jogadores))
+	for id, jogador := range jogadores {
+		if id != exceto && jogador.Conectado {
+			ocupadas[Ponto{jogador.PosX, jogador.PosY}] = true
+		}
+	}
+	return ocupadas
+}
+
+// tamanho da grade retangular que cobre o mapa (linhas podem ter larguras diferentes)
+func dimensoesMapa(mapa [][]Elemento) (largura, altura int) {
+	for _, linha := range mapa {
+		largura = max(largura, len(linha))
+	}
+	return largura, len(mapa)
+}
+
+// BuscarCaminho usa A* pra achar o caminho mais barato de origem até destino.
+// Devolve os passos sem incluir a origem (o último é o destino), ou nil se não existe caminho.
+func BuscarCaminho(mapa [][]Elemento, origem, destino Ponto, custo FuncaoCusto) []Ponto {
+	largura, altura := dimensoesMapa(mapa)
+	dentro := func(p Ponto) bool { return p.X >= 0 && p.X < largura && p.Y >= 0 && p.Y < altura }
+	if !dentro(origem) || !dentro(destino) {
+		return nil
+	}
+	if origem == destino {
+		return []Ponto{}
+	}
+	if _, ok := custo(destino.X, destino.Y); !ok {
+		return nil
+	}
+
+	// tudo em slices planas indexadas por y*largura+x pra escalar em mapas grandes
+	total := largura * altura
+	g := make([]int, total)
+	anterior := make([]int32, total)
+	fechado := make([]bool, total)
+	for i := range g {
+		g[i] = -1
+		anterior[i] = -1
+	}
+
+	indice := func(p Ponto) int { return p.Y*largura + p.X }
+	heuristica := func(p Ponto) int { return abs(p.X-destino.X) + abs(p.Y-destino.Y) }
+
+	inicio := indice(origem)
+	g[inicio] = 0
+	abertos := &filaPrioridade{{indice: inicio, f: heuristica(origem)}}
+	alvo := indice(destino)
+
+	for abertos.Len() > 0 {
+		atual := heap.Pop(abertos).(itemFila).indice
+		if atual == alvo {
+			break
+		}
+		if fechado[atual] {
+			continue // entrada velha na fila, já foi expandida com custo menor
+		}
+		fechado[atual] = true
+
+		p := Ponto{atual % largura, atual / largura}
+		for _, d := range direcoesCaminho {
+			q := Ponto{p.X + d.X, p.Y + d.Y}
+			if !dentro(q) {
+				continue
+			}
+			iq := indice(q)
+			if fechado[iq] {
+				continue
+			}
+			c, ok := custo(q.X, q.Y)
+			if !ok {
+				continue
+			}
+			novoG := g[atual] + c
+			if g[iq] >= 0 && novoG >= g[iq] {
+				continue
+			}
+			g[iq] = novoG
+			anterior[iq] = int32(atual)
+			heap.Push(abertos, itemFila{indice: iq, f: novoG + heuristica(q)})
+		}
+	}
+
+	if g[alvo] < 0 {
+		return nil
+	}
+
+	// reconstrói o caminho de trás pra frente
+	var caminho []Ponto
+	for i := alvo; i != inicio; i = int(anterior[i]) {
+		caminho = append(caminho, Ponto{i % largura, i / largura})
+	}
+	for i, j := 0, len(caminho)-1; i < j; i, j = i+1, j-1 {
+		caminho[i], caminho[j] = caminho[j], caminho[i]
+	}
+	return caminho
+}
+
+// resultado de uma BFS: número de passos da origem até cada célula
+type MapaDistancias struct {
+	largura int
+	dist    []int // -1 = inalcançável
+}
+
+// distância até o ponto e se ele é alcançável
+func (md *MapaDistancias) Distancia(p Ponto) (int, bool) {
+	if p.X < 0 || p.X >= md.largura || p.Y < 0 || md.largura == 0 || p.Y >= len(md.dist)/md.largura {
+		return 0, false
+	}
+	d := md.dist[p.Y*md.largura+p.X]
+	return d, d >= 0
+}
+
+// quantidade de células alcançáveis (incluindo a origem)
+func (md *MapaDistancias) Alcancaveis() int {
+	total := 0
+	for _, d := range md.dist {
+		if d >= 0 {
+			total++
+		}
+	}
+	return total
+}
+
+// DistanciasBFS calcula quantos passos separam a origem de cada célula
+// (ignora o custo, só a passabilidade).
+func DistanciasBFS(mapa [][]Elemento, origem Ponto, custo FuncaoCusto) *MapaDistancias {
+	largura, altura := dimensoesMapa(mapa)
+	md := &MapaDistancias{largura: largura, dist: make([]int, largura*altura)}
+	for i := range md.dist {
+		md.dist[i] = -1
+	}
+	if origem.X < 0 || origem.X >= largura || origem.Y < 0 || origem.Y >= altura {
+		return md
+	}
+
+	md.dist[origem.Y*largura+origem.X] = 0
+	fila := []int{origem.Y*largura + origem.X}
+	for len(fila) > 0 {
+		atual := fila[0]
+		fila = fila[1:]
+		p := Ponto{atual % largura, atual / largura}
+		for _, d := range direcoesCaminho {
+			q := Ponto{p.X + d.X, p.Y + d.Y}
+			if q.X < 0 || q.X >= largura || q.Y < 0 || q.Y >= altura {
+				continue
+			}
+			iq := q.Y*largura + q.X
+			if md.dist[iq] >= 0 {
+				continue
+			}
+			if _, ok := custo(q.X, q.Y); !ok {
+				continue
+			}
+			md.dist[iq] = md.dist[atual] + 1
+			fila = append(fila, iq)
+		}
+	}
+	return md
+}
+
+// Alcancavel diz se existe algum caminho entre dois pontos
+func Alcancavel(mapa [][]Elemento, origem, destino Ponto, custo FuncaoCusto) bool {
+	return BuscarCaminho(mapa, origem, destino, custo) != nil
+}
+
+// tecla de movimento (w, a, s, d) que leva de um ponto ao vizinho
+func TeclaPara(de, para Ponto) (rune, bool) {
+	switch (Ponto{para.X - de.X, para.Y - de.Y}) {
+	case Ponto{0, -1}:
+		return 'w', true
+	case Ponto{-1, 0}:
+		return 'a', true
+	case Ponto{0, 1}:
+		return 's', true
+	case Ponto{1, 0}:
+		return 'd', true
+	}
+	return 0, false
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// item da fila de prioridade do A*
+type itemFila struct {
+	indice int // célula (y*largura+x)
+	f      int // custo acumulado + heurística
+}
+
+// heap mínimo por f
+type filaPrioridade []itemFila
+
+func (f filaPrioridade) Len() int           { return len(f) }
+func (f filaPrioridade) Less(i, j int) bool { return f[i].f < f[j].f }
+func (f filaPrioridade) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f *filaPrioridade) Push(x any)        { *f = append(*f, x.(itemFila)) }
+func (f *filaPrioridade) Pop() any {
+	antigo := *f
+	item := antigo[len(antigo)-1]
+	*f = antigo[:len(antigo)-1]
+	return item
+}
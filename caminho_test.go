@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// monta o mapa a partir das linhas no formato do arquivo de mapa
+func mapaDeTeste(t testing.TB, linhas ...string) [][]Elemento {
+	t.Helper()
+	jogo := &Jogo{}
+	if err := CarregarMapaDeLinhas(linhas, jogo); err != nil {
+		t.Fatalf("erro ao carregar o mapa: %v", err)
+	}
+	return jogo.Mapa
+}
+
+// confere que o caminho sai da origem, anda uma célula por passo só por células passáveis e termina no destino
+func conferirCaminho(t *testing.T, caminho []Ponto, origem, destino Ponto, custo FuncaoCusto) {
+	t.Helper()
+	anterior := origem
+	for i, p := range caminho {
+		if abs(p.X-anterior.X)+abs(p.Y-anterior.Y) != 1 {
+			t.Fatalf("passo %d: %v não é vizinho de %v", i, p, anterior)
+		}
+		if _, ok := custo(p.X, p.Y); !ok {
+			t.Fatalf("passo %d: %v não é passável", i, p)
+		}
+		anterior = p
+	}
+	if anterior != destino {
+		t.Fatalf("o caminho termina em %v, não no destino %v", anterior, destino)
+	}
+}
+
+func TestBuscarCaminho(t *testing.T) {
+	// corredor de cima com vegetação (4 passos) ou a volta por baixo, limpa (8 passos)
+	comVegetacao := []string{
+		"▤▤▤▤▤▤▤",
+		"▤ ♣♣♣ ▤",
+		"▤ ▤▤▤ ▤",
+		"▤     ▤",
+		"▤▤▤▤▤▤▤",
+	}
+	aberto := []string{
+		"▤▤▤▤▤▤▤",
+		"▤     ▤",
+		"▤     ▤",
+		"▤     ▤",
+		"▤▤▤▤▤▤▤",
+	}
+	corredor := []string{
+		"▤▤▤▤▤▤▤",
+		"▤     ▤",
+		"▤▤▤▤▤▤▤",
+	}
+
+	casos := []struct {
+		nome     string
+		mapa     []string
+		origem   Ponto
+		destino  Ponto
+		custo    func(mapa [][]Elemento) FuncaoCusto
+		passos   int // -1 = sem caminho
+		evitando []Ponto
+	}{
+		{
+			nome:    "reto",
+			mapa:    aberto,
+			origem:  Ponto{1, 1},
+			destino: Ponto{5, 1},
+			passos:  4,
+		},
+		{
+			nome:    "contorna a parede",
+			mapa:    []string{"▤▤▤▤▤", "▤ ▤ ▤", "▤ ▤ ▤", "▤   ▤", "▤▤▤▤▤"},
+			origem:  Ponto{1, 1},
+			destino: Ponto{3, 1},
+			passos:  6,
+		},
+		{
+			nome:    "origem é o destino",
+			mapa:    aberto,
+			origem:  Ponto{2, 2},
+			destino: Ponto{2, 2},
+			passos:  0,
+		},
+		{
+			nome:    "destino é parede",
+			mapa:    aberto,
+			origem:  Ponto{1, 1},
+			destino: Ponto{0, 1},
+			passos:  -1,
+		},
+		{
+			nome:    "destino fora do mapa",
+			mapa:    aberto,
+			origem:  Ponto{1, 1},
+			destino: Ponto{9, 9},
+			passos:  -1,
+		},
+		{
+			nome:    "destino cercado",
+			mapa:    []string{"▤▤▤▤▤▤▤", "▤   ▤ ▤", "▤   ▤▤▤", "▤▤▤▤▤▤▤"},
+			origem:  Ponto{1, 1},
+			destino: Ponto{5, 1},
+			passos:  -1,
+		},
+		{
+			nome:    "vegetação com custo padrão vai pelo atalho",
+			mapa:    comVegetacao,
+			origem:  Ponto{1, 1},
+			destino: Ponto{5, 1},
+			passos:  4,
+		},
+		{
+			nome:    "vegetação cara faz dar a volta",
+			mapa:    comVegetacao,
+			origem:  Ponto{1, 1},
+			destino: Ponto{5, 1},
+			custo: func(mapa [][]Elemento) FuncaoCusto {
+				return ComCustoVegetacao(CustoPadrao(mapa), mapa, 5)
+			},
+			passos: 8,
+		},
+		{
+			nome:     "contorna a célula ocupada",
+			mapa:     aberto,
+			origem:   Ponto{1, 2},
+			destino:  Ponto{5, 2},
+			evitando: []Ponto{{3, 2}},
+			passos:   6,
+		},
+		{
+			nome:     "célula ocupada fecha o corredor",
+			mapa:     corredor,
+			origem:   Ponto{1, 1},
+			destino:  Ponto{5, 1},
+			evitando: []Ponto{{3, 1}},
+			passos:   -1,
+		},
+		{
+			nome:     "destino ocupado",
+			mapa:     aberto,
+			origem:   Ponto{1, 1},
+			destino:  Ponto{3, 3},
+			evitando: []Ponto{{3, 3}},
+			passos:   -1,
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			mapa := mapaDeTeste(t, c.mapa...)
+			custo := CustoPadrao(mapa)
+			if c.custo != nil {
+				custo = c.custo(mapa)
+			}
+			if len(c.evitando) > 0 {
+				ocupadas := make(map[Ponto]bool)
+				for _, p := range c.evitando {
+					ocupadas[p] = true
+				}
+				custo = EvitandoOcupadas(custo, ocupadas)
+			}
+
+			caminho := BuscarCaminho(mapa, c.origem, c.destino, custo)
+			if c.passos < 0 {
+				if caminho != nil {
+					t.Fatalf("esperava nenhum caminho, veio %v", caminho)
+				}
+				if Alcancavel(mapa, c.origem, c.destino, custo) {
+					t.Fatalf("Alcancavel diz que dá pra chegar, mas não tem caminho")
+				}
+				return
+			}
+			if caminho == nil {
+				t.Fatalf("esperava um caminho de %d passos, veio nil", c.passos)
+			}
+			if len(caminho) != c.passos {
+				t.Fatalf("esperava %d passos, veio %d: %v", c.passos, len(caminho), caminho)
+			}
+			conferirCaminho(t, caminho, c.origem, c.destino, custo)
+		})
+	}
+}
+
+func TestDistanciasBFS(t *testing.T) {
+	mapa := mapaDeTeste(t,
+		"▤▤▤▤▤▤▤",
+		"▤   ▤ ▤",
+		"▤ ▤   ▤",
+		"▤▤▤▤▤▤▤",
+	)
+	distancias := DistanciasBFS(mapa, Ponto{1, 1}, CustoPadrao(mapa))
+
+	casos := []struct {
+		ponto     Ponto
+		distancia int
+		ok        bool
+	}{
+		{Ponto{1, 1}, 0, true},
+		{Ponto{3, 1}, 2, true},
+		{Ponto{1, 2}, 1, true},
+		{Ponto{5, 1}, 6, true},
+		{Ponto{4, 1}, 0, false}, // parede
+		{Ponto{9, 9}, 0, false}, // fora do mapa
+	}
+	for _, c := range casos {
+		d, ok := distancias.Distancia(c.ponto)
+		if ok != c.ok || (ok && d != c.distancia) {
+			t.Errorf("Distancia(%v) = %d, %v; esperava %d, %v", c.ponto, d, ok, c.distancia, c.ok)
+		}
+	}
+	if n := distancias.Alcancaveis(); n != 8 {
+		t.Errorf("Alcancaveis() = %d, esperava 8", n)
+	}
+}
+
+func TestTeclaPara(t *testing.T) {
+	casos := []struct {
+		para  Ponto
+		tecla rune
+		ok    bool
+	}{
+		{Ponto{2, 1}, 'w', true},
+		{Ponto{1, 2}, 'a', true},
+		{Ponto{2, 3}, 's', true},
+		{Ponto{3, 2}, 'd', true},
+		{Ponto{3, 3}, 0, false},
+		{Ponto{2, 2}, 0, false},
+	}
+	for _, c := range casos {
+		tecla, ok := TeclaPara(Ponto{2, 2}, c.para)
+		if tecla != c.tecla || ok != c.ok {
+			t.Errorf("TeclaPara(%v) = %q, %v; esperava %q, %v", c.para, tecla, ok, c.tecla, c.ok)
+		}
+	}
+}
+
+// mapa gerado e o trajeto do primeiro spawn até a saída, pros benchmarks
+func trajetoBenchmark(b *testing.B, tipo string, largura, altura int) ([][]Elemento, Ponto, Ponto) {
+	b.Helper()
+	cfg := ConfigGeradorPadrao
+	cfg.Tipo, cfg.Largura, cfg.Altura = tipo, largura, altura
+	cfg.Inimigos = 0 // evita as verificações de conectividade caras em mapas enormes
+	linhas, err := GerarMapa(cfg)
+	if err != nil {
+		b.Fatalf("erro ao gerar mapa: %v", err)
+	}
+	jogo := &Jogo{}
+	if err := CarregarMapaDeLinhas(linhas, jogo); err != nil {
+		b.Fatalf("erro ao carregar o mapa: %v", err)
+	}
+
+	destino := Ponto{}
+	for y, linha := range jogo.Mapa {
+		for x, e := range linha {
+			if e.Simbolo == Saida.Simbolo {
+				destino = Ponto{x, y}
+			}
+		}
+	}
+	return jogo.Mapa, jogo.Spawns[0], destino
+}
+
+var tamanhosBenchmark = []struct{ largura, altura int }{{80, 40}, {320, 160}, {1000, 1000}}
+
+func BenchmarkBuscarCaminho(b *testing.B) {
+	for _, tipo := range []string{GeradorLabirinto, GeradorMasmorra} {
+		for _, t := range tamanhosBenchmark {
+			b.Run(fmt.Sprintf("%s/%dx%d", tipo, t.largura, t.altura), func(b *testing.B) {
+				mapa, origem, destino := trajetoBenchmark(b, tipo, t.largura, t.altura)
+				custo := CustoPadrao(mapa)
+				if BuscarCaminho(mapa, origem, destino, custo) == nil {
+					b.Fatalf("sem caminho de %v até %v", origem, destino)
+				}
+				for b.Loop() {
+					BuscarCaminho(mapa, origem, destino, custo)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDistanciasBFS(b *testing.B) {
+	for _, tipo := range []string{GeradorLabirinto, GeradorMasmorra} {
+		for _, t := range tamanhosBenchmark {
+			b.Run(fmt.Sprintf("%s/%dx%d", tipo, t.largura, t.altura), func(b *testing.B) {
+				mapa, origem, _ := trajetoBenchmark(b, tipo, t.largura, t.altura)
+				custo := CustoPadrao(mapa)
+				for b.Loop() {
+					DistanciasBFS(mapa, origem, custo)
+				}
+			})
+		}
+	}
+}
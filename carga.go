@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/rpc"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// cenários do teste de carga
+const (
+	CenarioConectar  = "conectar"  // conecta e desconecta sem parar
+	CenarioMover     = "mover"     // conecta uma vez e só anda
+	CenarioConsultar = "consultar" // conecta uma vez e só pede posições
+	CenarioMisto     = "misto"     // mistura de tudo, parecido com um jogador de verdade
+)
+
+// configuração do teste de carga
+type ConfigCarga struct {
+	Conexoes  int           // quantas conexões rpc.Client abrir ao mesmo tempo
+	Duracao   time.Duration // quanto tempo o teste roda
+	Cenario   string        // um dos cenários acima
+	Intervalo time.Duration // pausa entre chamadas de cada conexão (0 = o mais rápido possível)
+}
+
+// medições de uma conexão (cada worker tem a sua, juntadas no final)
+type medicoesCarga struct {
+	latencias map[string][]time.Duration // RPC -> latência de cada chamada que deu certo
+	erros     map[string]int             // RPC -> quantidade de erros
+}
+
+func novasMedicoes() *medicoesCarga {
+	return &medicoesCarga{
+		latencias: make(map[string][]time.Duration),
+		erros:     make(map[string]int),
+	}
+}
+
+// faz uma chamada medindo o tempo
+func (w *workerCarga) chamar(metodo string, args, reply any) error {
+	inicio := time.Now()
+	err := w.cliente.Call("GameService."+metodo, args, reply)
+	if err != nil {
+		w.medicoes.erros[metodo]++
+		if errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			w.caiu = true // conexão morreu: não adianta continuar chamando
+		}
+		return err
+	}
+	w.medicoes.latencias[metodo] = append(w.medicoes.latencias[metodo], time.Since(inicio))
+	return nil
+}
+
+// junta as medições de outro worker nesta
+func (m *medicoesCarga) juntar(outra *medicoesCarga) {
+	for metodo, lat := range outra.latencias {
+		m.latencias[metodo] = append(m.latencias[metodo], lat...)
+	}
+	for metodo, n := range outra.erros {
+		m.erros[metodo] += n
+	}
+}
+
+// um cliente simulado do teste de carga
+type workerCarga struct {
+	id        int
+	cliente   *rpc.Client
+	jogadorID string
+	token     string
+	senha     string
+	seq       int64
+	rng       *rand.Rand
+	medicoes  *medicoesCarga
+	caiu      bool // a conexão fechou e o worker parou
+}
+
+func (w *workerCarga) conectar() error {
+	var resp ConectarPosicaoResponse
+	req := ConectarRequest{Nome: fmt.Sprintf("Carga%04d", w.id), Senha: w.senha}
+	if err := w.chamar("ConectarJogo", req, &resp); err != nil {
+		return err
+	}
+	w.jogadorID = resp.JogadorID
+	w.token = resp.Token
+	w.seq = 0
+	return nil
+}
+
+func (w *workerCarga) desconectar() error {
+	var ok bool
+	err := w.chamar("Desconectar", SessaoRequest{w.jogadorID, w.token}, &ok)
+	w.jogadorID = ""
+	return err
+}
+
+func (w *workerCarga) mover() error {
+	w.seq++
+	req := MoverRequest{
+		JogadorID:      w.jogadorID,
+		Token:          w.token,
+		SequenceNumber: w.seq,
+		Tecla:          []rune{'w', 'a', 's', 'd'}[w.rng.Intn(4)],
+	}
+	var posicoes PosicoesJogadores
+	return w.chamar("Mover", req, &posicoes)
+}
+
+func (w *workerCarga) consultar() error {
+	var posicoes PosicoesJogadores
+	return w.chamar("ObterPosicoes", SessaoRequest{w.jogadorID, w.token}, &posicoes)
+}
+
+// executa um passo do cenário escolhido
+func (w *workerCarga) passo(cenario string) {
+	switch cenario {
+	case CenarioConectar:
+		if w.conectar() == nil {
+			w.desconectar()
+		}
+	case CenarioMover:
+		w.mover()
+	case CenarioConsultar:
+		w.consultar()
+	case CenarioMisto:
+		switch r := w.rng.Intn(100); {
+		case r < 70:
+			w.mover()
+		case r < 97:
+			w.consultar()
+		default:
+			// de vez em quando sai e volta, como um jogador que caiu
+			w.desconectar()
+			w.conectar()
+		}
+	}
+}
+
+// RunTesteCarga abre as conexões, roda o cenário e devolve as medições juntadas
+func RunTesteCarga(config NetworkConfig, cfg ConfigCarga) (*medicoesCarga, time.Duration, error) {
+	switch cfg.Cenario {
+	case CenarioConectar, CenarioMover, CenarioConsultar, CenarioMisto:
+	default:
+		return nil, 0, fmt.Errorf("cenário desconhecido: %q", cfg.Cenario)
+	}
+
+	total := novasMedicoes()
+	var totalMutex sync.Mutex
+	var prontos, wg sync.WaitGroup
+	largada := make(chan struct{})
+	fim := make(chan struct{})
+	falhas := 0
+
+	for i := 0; i < cfg.Conexoes; i++ {
+		conn, err := config.Dial()
+		if err != nil {
+			falhas++
+			continue
+		}
+		cliente := rpc.NewClient(conn)
+		if _, err := fazerHandshake(cliente, "teste de carga"); err != nil {
+			cliente.Close()
+			falhas++
+			continue
+		}
+		w := &workerCarga{id: i, cliente: cliente, senha: config.Senha, rng: rand.New(rand.NewSource(int64(i))), medicoes: novasMedicoes()}
+
+		prontos.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cliente.Close()
+
+			// cenários que precisam de jogador já conectam antes da largada
+			if cfg.Cenario != CenarioConectar {
+				w.conectar()
+			}
+			prontos.Done()
+			<-largada
+
+			for {
+				select {
+				case <-fim:
+					if w.jogadorID != "" && !w.caiu {
+						w.desconectar()
+					}
+					totalMutex.Lock()
+					total.juntar(w.medicoes)
+					totalMutex.Unlock()
+					return
+				default:
+				}
+				if w.caiu {
+					time.Sleep(10 * time.Millisecond) // só espera o fim do teste
+					continue
+				}
+				w.passo(cfg.Cenario)
+				if cfg.Intervalo > 0 {
+					time.Sleep(cfg.Intervalo)
+				}
+			}
+		}()
+	}
+	if falhas > 0 {
+		log.Printf("%d conexões falharam ao abrir", falhas)
+		total.erros["Dial"] += falhas
+	}
+
+	prontos.Wait()
+	log.Printf("%d conexões prontas, rodando cenário %q por %v", cfg.Conexoes-falhas, cfg.Cenario, cfg.Duracao)
+
+	inicio := time.Now()
+	close(largada)
+	time.Sleep(cfg.Duracao)
+	close(fim)
+	duracao := time.Since(inicio)
+	wg.Wait()
+
+	return total, duracao, nil
+}
+
+// percentil p (0 a 100) de uma lista já ordenada
+func percentil(ordenadas []time.Duration, p float64) time.Duration {
+	if len(ordenadas) == 0 {
+		return 0
+	}
+	i := int(float64(len(ordenadas)-1) * p / 100)
+	return ordenadas[i]
+}
+
+// imprime vazão, percentis de latência e erros de cada RPC
+func imprimirRelatorioCarga(m *medicoesCarga, duracao time.Duration) {
+	metodos := make([]string, 0, len(m.latencias)+len(m.erros))
+	for metodo := range m.latencias {
+		metodos = append(metodos, metodo)
+	}
+	for metodo := range m.erros {
+		if _, existe := m.latencias[metodo]; !existe {
+			metodos = append(metodos, metodo)
+		}
+	}
+	sort.Strings(metodos)
+
+	fmt.Printf("\n%-14s %9s %10s %10s %10s %10s %10s %7s\n", "RPC", "chamadas", "por seg", "p50", "p90", "p99", "máx", "erros")
+	chamadas, erros := 0, 0
+	for _, metodo := range metodos {
+		lat := m.latencias[metodo]
+		slices.Sort(lat)
+		fmt.Printf("%-14s %9d %10.1f %10v %10v %10v %10v %7d\n",
+			metodo, len(lat), float64(len(lat))/duracao.Seconds(),
+			percentil(lat, 50).Round(time.Microsecond), percentil(lat, 90).Round(time.Microsecond),
+			percentil(lat, 99).Round(time.Microsecond), percentil(lat, 100).Round(time.Microsecond),
+			m.erros[metodo])
+		chamadas += len(lat)
+		erros += m.erros[metodo]
+	}
+	fmt.Printf("\nTotal: %d chamadas em %v (%.1f por segundo), %d erros\n",
+		chamadas, duracao.Round(time.Millisecond), float64(chamadas)/duracao.Seconds(), erros)
+}
+
+// roda o teste de carga pela linha de comando
+func runTesteCarga(config NetworkConfig, cfg ConfigCarga) {
+	medicoes, duracao, err := RunTesteCarga(config, cfg)
+	if err != nil {
+		log.Fatal("Erro no teste de carga:", err)
+	}
+	imprimirRelatorioCarga(medicoes, duracao)
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/rpc"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,11 +15,24 @@ type GameClient struct {
 	client         *rpc.Client   // conexão com o servidor via rpc
 	config         NetworkConfig // configurações de rede (ex: ip, porta)
 	sequenceNumber int64         // número de sequência pra garantir ordem dos comandos
+	versaoServidor int           // versão de protocolo combinada no handshake
+	lote           *loteCliente  // movimentos esperando pra sair num lote (nil = manda cada um na hora)
 	gameManager    *GameManager  // gerenciador do jogo local
 	jogadorID      string        // id único do jogador nesse cliente
+	token          string        // segredo da sessão, mandado em todas as RPCs
 	mutex          sync.RWMutex  // trava de leitura/escrita pra acessar dados com segurança
 	sincronizando  bool          // flag que diz se a sincronização tá rolando
 	stopSync       chan bool     // canal pra mandar sinal de parar a sincronização
+	pararViagem    chan struct{} // fecha pra cancelar a caminhada automática (nil = parado)
+	marca          *Ponto        // célula marcada pelo jogador pra viajar depois
+	indiceAlvo     int           // último jogador escolhido como alvo de viagem
+	renderizador   Renderizador  // quem recebe os estados novos (termbox, nulo, gravador...)
+	avisoID        int64         // último aviso do servidor mostrado
+	versaoMapa     int64         // versão do mapa do servidor que está carregada
+	desligamento   string        // motivo anunciado pelo servidor, se ele estiver desligando
+	caiu           chan struct{} // fechado quando a conexão com o servidor cai
+	quedaUmaVez    sync.Once
+	motivoQueda    string // por que a conexão caiu (mostrado na tela de reconexão)
 }
 
 // Cria um novo cliente com a config padrão
@@ -27,32 +43,111 @@ func NewGameClient() (*GameClient, error) {
 // Cria um novo cliente com uma config específica
 func NewGameClientWithConfig(config NetworkConfig) (*GameClient, error) {
 	log.Println("Conectando cliente ao servidor em", config.GetAddress())
-	client, err := rpc.Dial("tcp", config.GetAddress()) // tenta conectar no servidor
+	conn, err := config.Dial() // tenta conectar no servidor (com TLS se configurado)
 	if err != nil {
 		return nil, err
 	}
+	client := rpc.NewClient(conn)
 
-	return &GameClient{
-		client:      client,
-		config:      config,
-		gameManager: NewGameManager(),
-		stopSync:    make(chan bool),
-	}, nil
+	// Combina a versão do protocolo antes de qualquer outra coisa
+	versao, err := fazerHandshake(client, "cliente termbox")
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	gc := &GameClient{
+		client:         client,
+		config:         config,
+		versaoServidor: versao,
+		gameManager:    NewGameManager(),
+		stopSync:       make(chan bool),
+		renderizador:   RenderizadorNulo{},
+		caiu:           make(chan struct{}),
+	}
+	gc.iniciarLote()
+	return gc, nil
+}
+
+// Troca o renderizador que recebe as atualizações de estado
+func (gc *GameClient) DefinirRenderizador(r Renderizador) {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+	gc.renderizador = r
+}
+
+// Manda o estado atual pro renderizador se o jogador local ainda está no jogo
+func (gc *GameClient) notificar() {
+	estado := gc.gameManager.ObterEstado()
+
+	gc.mutex.RLock()
+	jogadorAtual := estado.Jogadores[gc.jogadorID]
+	renderizador := gc.renderizador
+	gc.mutex.RUnlock()
+
+	if jogadorAtual != nil || gc.config.Espectador {
+		renderizador.Desenhar(estado)
+	}
+}
+
+// Diz se o cliente entrou só pra assistir
+func (gc *GameClient) Espectador() bool {
+	return gc.config.Espectador
 }
 
 // Fecha o cliente (desconecta e para a sync)
 func (gc *GameClient) Close() error {
+	gc.CancelarViagem()
 	gc.PararSincronizacao()
+	gc.pararLote() // manda os movimentos que ainda estavam esperando
 
 	// Tenta desconectar o jogador do servidor
 	if gc.jogadorID != "" {
 		var resposta bool
-		gc.client.Call("GameService.Desconectar", gc.jogadorID, &resposta)
+		gc.client.Call("GameService.Desconectar", gc.sessao(), &resposta)
 	}
 
 	return gc.client.Close()
 }
 
+// Fechado quando a conexão com o servidor cai (servidor desligou, rede caiu, expulsão)
+func (gc *GameClient) Caiu() <-chan struct{} {
+	return gc.caiu
+}
+
+// Por que a conexão caiu
+func (gc *GameClient) MotivoQueda() string {
+	gc.mutex.RLock()
+	defer gc.mutex.RUnlock()
+	return gc.motivoQueda
+}
+
+// Confere se o erro de uma RPC quer dizer que a conexão acabou
+func (gc *GameClient) verificarQueda(err error) {
+	var errServidor rpc.ServerError
+	if err == nil || errors.As(err, &errServidor) {
+		return // erro devolvido pelo servidor: a conexão continua de pé
+	}
+
+	gc.quedaUmaVez.Do(func() {
+		gc.mutex.Lock()
+		if gc.desligamento != "" {
+			gc.motivoQueda = "O servidor desligou: " + gc.desligamento
+		} else {
+			gc.motivoQueda = "A conexão com o servidor caiu (" + err.Error() + ")"
+		}
+		gc.mutex.Unlock()
+		close(gc.caiu)
+	})
+}
+
+// Identificação da sessão usada nas RPCs
+func (gc *GameClient) sessao() SessaoRequest {
+	gc.mutex.RLock()
+	defer gc.mutex.RUnlock()
+	return SessaoRequest{JogadorID: gc.jogadorID, Token: gc.token}
+}
+
 // Retorna o gerenciador de jogo local
 func (gc *GameClient) GetGameManager() *GameManager {
 	return gc.gameManager
@@ -65,15 +160,22 @@ func (gc *GameClient) GetDefaultMapFile() string {
 
 // Conecta o jogador no jogo
 func (gc *GameClient) ConectarJogo(mapaFile string) (string, error) {
-	// Inicializa o jogo local com o mapa
-	if err := gc.gameManager.InicializarJogo(mapaFile); err != nil {
-		return "", err
+	nome := gc.config.Nome
+	if nome == "" {
+		nome = "Jogador" + time.Now().Format("15:04:05")
 	}
+	return gc.ConectarJogoComNome(mapaFile, nome)
+}
 
+// Conecta o jogador no jogo usando um nome escolhido
+func (gc *GameClient) ConectarJogoComNome(mapaFile, nome string) (string, error) {
 	// Prepara a requisição para o servidor
 	req := ConectarRequest{
-		MapaFile: mapaFile,
-		Nome:     "Jogador" + time.Now().Format("15:04:05"),
+		MapaFile:   mapaFile,
+		Nome:       nome,
+		Senha:      gc.config.Senha,
+		Segredo:    gc.config.Segredo,
+		Espectador: gc.config.Espectador,
 	}
 
 	// Chama o servidor para conectar
@@ -83,11 +185,30 @@ func (gc *GameClient) ConectarJogo(mapaFile string) (string, error) {
 		return "", err
 	}
 
+	// Inicializa o jogo local com o mapa do servidor ou, se ele não mandou, com o arquivo local
+	if len(resp.Mapa) > 0 {
+		err = gc.gameManager.InicializarJogoComLinhas(resp.Mapa)
+	} else {
+		err = gc.gameManager.InicializarJogo(mapaFile)
+	}
+	if err != nil {
+		return "", err
+	}
+
 	// Guarda o ID do jogador
 	gc.mutex.Lock()
 	gc.jogadorID = resp.JogadorID
+	gc.token = resp.Token
+	gc.versaoMapa = resp.Posicoes.VersaoMapa // o mapa da resposta já é o atual
 	gc.mutex.Unlock()
 
+	// Espectador não tem personagem: a câmera começa mostrando o mapa inteiro
+	if gc.config.Espectador {
+		gc.gameManager.IniciarEspectador()
+		gc.aplicarPosicoes(resp.Posicoes)
+		return resp.JogadorID, nil
+	}
+
 	// Encontra os dados do jogador local nas posições recebidas
 	jogadorLocal := resp.Posicoes.Jogadores[resp.JogadorID]
 
@@ -97,19 +218,27 @@ func (gc *GameClient) ConectarJogo(mapaFile string) (string, error) {
 		jogadorLocal.Nome,
 		jogadorLocal.PosX,
 		jogadorLocal.PosY,
-		jogadorLocal.Cor,
+		jogadorLocal.Cor.Terminal(),
 	)
 
-	// Atualiza as posições dos outros jogadores
-	gc.gameManager.AtualizarJogadoresRemotos(resp.Posicoes.Jogadores)
+	// Atualiza as posições dos outros jogadores (e mostra o aviso atual, se houver)
+	gc.aplicarPosicoes(resp.Posicoes)
 
 	return resp.JogadorID, nil
 }
 
 // Envia um movimento pro servidor e atualiza o estado local
 func (gc *GameClient) Mover(jogadorID string, tecla rune) error {
-	// Incrementa o número de sequência
-	gc.sequenceNumber++
+	// Com o -coalescer o movimento só é previsto aqui e sai no próximo lote
+	if gc.lote != nil {
+		gc.gameManager.MoverJogadorLocal(tecla)
+		gc.juntarNoLote(comandoLoteMover(tecla))
+		gc.notificar()
+		return nil
+	}
+
+	// Incrementa o número de sequência (a caminhada automática também move)
+	seq := atomic.AddInt64(&gc.sequenceNumber, 1)
 
 	// Atualiza o movimento localmente primeiro
 	gc.gameManager.MoverJogadorLocal(tecla)
@@ -117,7 +246,8 @@ func (gc *GameClient) Mover(jogadorID string, tecla rune) error {
 	// Prepara a requisição para o servidor
 	req := MoverRequest{
 		JogadorID:      jogadorID,
-		SequenceNumber: gc.sequenceNumber,
+		Token:          gc.token,
+		SequenceNumber: seq,
 		Tecla:          tecla,
 	}
 
@@ -125,11 +255,13 @@ func (gc *GameClient) Mover(jogadorID string, tecla rune) error {
 	var posicoes PosicoesJogadores
 	err := gc.client.Call("GameService.Mover", req, &posicoes)
 	if err != nil {
+		gc.verificarQueda(err)
 		return err
 	}
 
 	// Atualiza o estado local com as posições recebidas do servidor
-	gc.gameManager.AtualizarJogadoresRemotos(posicoes.Jogadores)
+	gc.aplicarPosicoes(posicoes)
+	gc.notificar()
 
 	return nil
 }
@@ -137,17 +269,59 @@ func (gc *GameClient) Mover(jogadorID string, tecla rune) error {
 // Obtém as posições atualizadas do servidor
 func (gc *GameClient) ObterPosicoes() error {
 	var posicoes PosicoesJogadores
-	err := gc.client.Call("GameService.ObterPosicoes", gc.jogadorID, &posicoes)
+	err := gc.client.Call("GameService.ObterPosicoes", gc.sessao(), &posicoes)
 	if err != nil {
+		gc.verificarQueda(err)
 		return err
 	}
 
 	// Atualiza o jogo local com as posições mais recentes
-	gc.gameManager.AtualizarJogadoresRemotos(posicoes.Jogadores)
+	gc.aplicarPosicoes(posicoes)
 
 	return nil
 }
 
+// Aplica as posições e o que mais veio junto: mapa novo, aviso da administração
+func (gc *GameClient) aplicarPosicoes(posicoes PosicoesJogadores) {
+	gc.mutex.Lock()
+	mapaMudou := posicoes.VersaoMapa != gc.versaoMapa
+	avisoNovo := posicoes.AvisoID != gc.avisoID
+	gc.avisoID = posicoes.AvisoID
+	gc.desligamento = posicoes.Desligamento
+	gc.mutex.Unlock()
+
+	// o mapa vem antes das posições, que já são do mapa novo
+	if mapaMudou {
+		gc.baixarMapa()
+	}
+	switch {
+	case posicoes.Desligamento != "":
+		// a contagem regressiva toma o lugar do aviso enquanto durar
+		segundos := int(posicoes.DesligaEm.Round(time.Second).Seconds())
+		gc.gameManager.DefinirAviso(fmt.Sprintf("Servidor desligando em %ds: %s", segundos, posicoes.Desligamento))
+	case avisoNovo:
+		gc.gameManager.DefinirAviso(posicoes.Aviso)
+	}
+	gc.gameManager.AtualizarJogadoresRemotos(posicoes.Jogadores)
+	gc.gameManager.DefinirChat(posicoes.Chat)
+}
+
+// Baixa o mapa atual do servidor (se falhar, tenta de novo na próxima sincronização)
+func (gc *GameClient) baixarMapa() {
+	var resp ObterMapaResponse
+	if err := gc.client.Call("GameService.ObterMapa", gc.sessao(), &resp); err != nil {
+		return
+	}
+	if err := gc.gameManager.TrocarMapa(resp.Mapa); err != nil {
+		log.Println("Erro ao trocar o mapa:", err)
+		return
+	}
+
+	gc.mutex.Lock()
+	gc.versaoMapa = resp.VersaoMapa
+	gc.mutex.Unlock()
+}
+
 // Obtém o estado atual do jogo local
 func (gc *GameClient) ObterEstado() (*EstadoJogo, error) {
 	// Tenta atualizar com as posições mais recentes do servidor
@@ -200,20 +374,12 @@ func (gc *GameClient) loopSincronizacao() {
 			return
 		case <-ticker.C:
 			// Hora de sincronizar: pega posições do servidor
-			estado, err := gc.ObterEstado()
-			if err != nil {
+			if err := gc.ObterPosicoes(); err != nil {
 				continue // se der erro, só ignora e tenta dnv na próxima
 			}
 
-			// Verifica se o jogador ainda está no jogo
-			gc.mutex.RLock()
-			jogadorAtual := estado.Jogadores[gc.jogadorID]
-			gc.mutex.RUnlock()
-
-			if jogadorAtual != nil {
-				// Desenha o estado na tela
-				DesenharEstadoJogo(estado)
-			}
+			// Avisa o renderizador (que desenha na tela, se for o termbox)
+			gc.notificar()
 		}
 	}
 }
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Comandos genéricos: uma RPC só (Comando) e um registro com o que o servidor
+// sabe fazer. Cada comando decodifica os próprios dados e roda na vez dele na
+// fila do jogador, junto com os movimentos do Mover. Sessão, limite de
+// velocidade, sequência repetida e o formato da resposta são iguais pra todos,
+// então um comando novo é uma função e uma linha em comandosServidor.
+
+var (
+	ErrComandoDesconhecido = errors.New("comando desconhecido")
+	ErrDadosComando        = errors.New("dados do comando inválidos")
+	ErrFilaCheia           = errors.New("muitas ações esperando, tente de novo")
+	ErrAcaoDescartada      = errors.New("ação descartada: o servidor mudou o jogador de lugar")
+	ErrNadaParaInteragir   = errors.New("não tem nada para interagir aqui do lado")
+	ErrSemComandos         = errors.New("o servidor é de uma versão antiga, sem comandos")
+)
+
+const (
+	tamanhoMaximoChat = 200             // maior mensagem de chat, em caracteres
+	mensagensChat     = 5               // quantas mensagens de chat vão junto com as posições
+	duracaoEmote      = 3 * time.Second // quanto tempo o emote fica aparecendo
+)
+
+// prepara um comando fora do loop (decodifica e valida os dados) e devolve o que roda na vez dele
+type manipuladorComando func(gs *GameServer, req ComandoRequest) (func() (any, error), error)
+
+// dados que se validam antes de entrar na fila
+type dadosValidaveis interface {
+	validar() error
+}
+
+// comandos que o servidor conhece
+var comandosServidor = map[string]manipuladorComando{
+	ComandoMover:     comandoTipado(comandoMover),
+	ComandoInteragir: comandoTipado(comandoInteragir),
+	ComandoChat:      comandoTipado(comandoChat),
+	ComandoEmote:     comandoTipado(comandoEmote),
+}
+
+// manipulador de um comando cujos dados chegam em JSON no formato de T (dados vazios = T zerado)
+func comandoTipado[T any](executar func(gs *GameServer, req ComandoRequest, dados T) (any, error)) manipuladorComando {
+	return func(gs *GameServer, req ComandoRequest) (func() (any, error), error) {
+		var dados T
+		if len(req.Dados) > 0 {
+			if err := json.Unmarshal(req.Dados, &dados); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrDadosComando, err)
+			}
+		}
+		if v, ok := any(dados).(dadosValidaveis); ok {
+			if err := v.validar(); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrDadosComando, err)
+			}
+		}
+		return func() (any, error) { return executar(gs, req, dados) }, nil
+	}
+}
+
+// RPC: executa um comando do jogador e espera ele ser aplicado. Só sessão
+// inválida vira erro da RPC; o resto vem no Resultado, igual pra todo comando.
+func (gs *GameService) Comando(req ComandoRequest, reply *ComandoResponse) error {
+	if err := gs.verificarSessao(req.JogadorID, req.Token, "Comando"); err != nil {
+		return err
+	}
+	gs.servidor.registrarAtividade(req.JogadorID)
+
+	reply.Resultado = gs.executarComando(req)
+	reply.Posicoes = gs.servidor.posicoesPara(req.JogadorID)
+	return nil
+}
+
+// valida o comando, põe na fila do jogador e espera o resultado
+func (gs *GameService) executarComando(req ComandoRequest) ResultadoComando {
+	acao, err := gs.prepararAcao(req)
+	if err == nil {
+		err = gs.limitarAcao(req.JogadorID, req.SequenceNumber, 1)
+	}
+	if err != nil {
+		return resultadoErro(acao, err)
+	}
+
+	// repetido de um já aplicado: responde na hora (o que ainda está na fila o loop confere)
+	if req.SequenceNumber <= gs.servidor.snapshot.Load().processados[req.JogadorID] {
+		return ResultadoComando{SequenceNumber: req.SequenceNumber, Comando: req.Comando, Duplicado: true}
+	}
+
+	if err := gs.enfileirar(acao); err != nil {
+		return resultadoErro(acao, err)
+	}
+	return gs.esperarResultado(acao)
+}
+
+// confere o nome e os dados do comando e monta a ação que vai pra fila
+// (mesmo com erro, a ação volta com o número e o nome, pro resultado)
+func (gs *GameService) prepararAcao(req ComandoRequest) (acaoJogador, error) {
+	acao := acaoJogador{JogadorID: req.JogadorID, SequenceNumber: req.SequenceNumber, Comando: req.Comando}
+
+	preparar, existe := comandosServidor[req.Comando]
+	if !existe {
+		return acao, fmt.Errorf("%w: %q", ErrComandoDesconhecido, req.Comando)
+	}
+	aplicar, err := preparar(gs.servidor, req)
+	if err != nil {
+		return acao, err
+	}
+	acao.aplicar = aplicar
+	acao.resposta = make(chan ResultadoComando, 1)
+	return acao, nil
+}
+
+// põe a ação na fila do jogador (o loop responde cada comando pelo canal dele)
+func (gs *GameService) enfileirar(acao acaoJogador) error {
+	select {
+	case gs.servidor.entradas <- acao:
+		return nil
+	case <-gs.servidor.parado:
+		return ErrServidorDesligado
+	}
+}
+
+// espera o loop aplicar uma ação que já está na fila
+func (gs *GameService) esperarResultado(acao acaoJogador) ResultadoComando {
+	select {
+	case resultado := <-acao.resposta:
+		return resultado
+	case <-gs.servidor.parado:
+		return resultadoErro(acao, ErrServidorDesligado)
+	}
+}
+
+// resultado de uma ação que não chegou a ser aplicada
+func resultadoErro(acao acaoJogador, err error) ResultadoComando {
+	return ResultadoComando{SequenceNumber: acao.SequenceNumber, Comando: acao.Comando, Erro: err.Error()}
+}
+
+// ---- comandos (rodam dentro do loop) ----
+
+func comandoMover(gs *GameServer, req ComandoRequest, dados DadosMover) (any, error) {
+	gs.aplicarMovimento(MoverRequest{JogadorID: req.JogadorID, SequenceNumber: req.SequenceNumber, Tecla: dados.Tecla})
+	return nil, nil
+}
+
+// derrota um inimigo numa das quatro células vizinhas e devolve onde ele estava
+func comandoInteragir(gs *GameServer, req ComandoRequest, _ struct{}) (any, error) {
+	jogador, existe := gs.jogadores[req.JogadorID]
+	if !existe || gs.elementos == nil {
+		return nil, ErrNadaParaInteragir
+	}
+
+	for _, tecla := range "wasd" {
+		dx, dy, _ := direcaoTecla(tecla)
+		x, y := jogador.PosX+dx, jogador.PosY+dy
+		if y < 0 || y >= len(gs.elementos) || x < 0 || x >= len(gs.elementos[y]) ||
+			gs.elementos[y][x].Simbolo != Inimigo.Simbolo {
+			continue
+		}
+
+		gs.trocarCelula(x, y, Vazio)
+		if sessao, existe := gs.sessoes[req.JogadorID]; existe {
+			gs.perfis.Registrar(sessao.perfil, func(e *EstatisticasJogador) { e.InimigosDerrotados++ })
+		}
+		log.Printf("%s derrotou o inimigo em (%d, %d)", jogador.Nome, x, y)
+		return Ponto{x, y}, nil
+	}
+	return nil, ErrNadaParaInteragir
+}
+
+// guarda a mensagem entre as últimas do chat e devolve ela com o id
+func comandoChat(gs *GameServer, req ComandoRequest, dados DadosChat) (any, error) {
+	jogador, existe := gs.jogadores[req.JogadorID]
+	if !existe {
+		return nil, ErrSessaoInvalida
+	}
+
+	gs.chatID++
+	msg := MensagemChat{ID: gs.chatID, JogadorID: req.JogadorID, Nome: jogador.Nome, Texto: strings.TrimSpace(dados.Texto)}
+	// fatia nova a cada mensagem: a do snapshot publicado nunca muda
+	gs.chat = append(slices.Clone(gs.chat[max(0, len(gs.chat)-mensagensChat+1):]), msg)
+	gs.gravar(EventoReplay{Tipo: EventoChat, Jogador: req.JogadorID, Nome: jogador.Nome, Texto: msg.Texto})
+	log.Printf("[chat] %s: %s", jogador.Nome, msg.Texto)
+	return msg, nil
+}
+
+// mostra o emote em cima do jogador por alguns segundos
+func comandoEmote(gs *GameServer, req ComandoRequest, dados DadosEmote) (any, error) {
+	jogador, existe := gs.jogadores[req.JogadorID]
+	if !existe {
+		return nil, ErrSessaoInvalida
+	}
+	jogador.Emote = dados.Emote
+	gs.jogadores[req.JogadorID] = jogador
+	gs.emotes[req.JogadorID] = gs.tick + int64(duracaoEmote.Seconds()*float64(gs.config.TaxaTick))
+	return nil, nil
+}
+
+// apaga os emotes que já passaram do tempo (só chamado dentro do loop)
+func (gs *GameServer) expirarEmotes() {
+	for id, fim := range gs.emotes {
+		if gs.tick < fim {
+			continue
+		}
+		if jogador, existe := gs.jogadores[id]; existe {
+			jogador.Emote = ""
+			gs.jogadores[id] = jogador
+		}
+		delete(gs.emotes, id)
+	}
+}
+
+// troca uma célula do mapa e faz os clientes baixarem o mapa de novo (só chamado dentro do loop)
+func (gs *GameServer) trocarCelula(x, y int, elem Elemento) {
+	gs.elementos[y][x] = elem
+
+	linhas := slices.Clone(gs.mapa) // as linhas publicadas nunca mudam
+	if linha := []rune(linhas[y]); x < len(linha) {
+		linha[x] = elem.Simbolo
+		linhas[y] = string(linha)
+	}
+	gs.mapa = linhas
+	gs.versaoMapa++
+	gs.gravar(EventoReplay{Tipo: EventoMapa, Mapa: linhas})
+}
+
+// ---- validação dos dados ----
+
+func (d DadosMover) validar() error {
+	if _, _, ok := direcaoTecla(d.Tecla); !ok {
+		return fmt.Errorf("tecla %q não é de movimento", d.Tecla)
+	}
+	return nil
+}
+
+func (d DadosChat) validar() error {
+	texto := strings.TrimSpace(d.Texto)
+	switch {
+	case texto == "":
+		return errors.New("mensagem vazia")
+	case utf8.RuneCountInString(texto) > tamanhoMaximoChat:
+		return fmt.Errorf("mensagem com mais de %d caracteres", tamanhoMaximoChat)
+	case strings.IndexFunc(texto, unicode.IsControl) >= 0:
+		return errors.New("mensagem com caracteres de controle")
+	}
+	return nil
+}
+
+func (d DadosEmote) validar() error {
+	if _, existe := SimbolosEmote[d.Emote]; !existe {
+		return fmt.Errorf("emote %q não existe (use %s)", d.Emote, strings.Join(NomesEmote(), ", "))
+	}
+	return nil
+}
+
+// nomes dos emotes em ordem alfabética
+func NomesEmote() []string {
+	nomes := make([]string, 0, len(SimbolosEmote))
+	for nome := range SimbolosEmote {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+	return nomes
+}
+
+// ---- cliente ----
+
+// Manda um comando pro servidor e espera o resultado. Erro do comando (ex: nada
+// pra interagir) volta como error com o texto do servidor, junto com o resultado.
+func (gc *GameClient) Comando(comando string, dados any) (ResultadoComando, error) {
+	if gc.versaoServidor < VersaoComandos {
+		return ResultadoComando{}, ErrSemComandos
+	}
+	if gc.lote != nil {
+		// os movimentos juntados vieram antes: vão primeiro, e o número deste comando sai depois dos deles
+		gc.lote.envio.Lock()
+		defer gc.lote.envio.Unlock()
+		if err := gc.enviarPendentes(); err != nil {
+			return ResultadoComando{}, err
+		}
+	}
+
+	sessao := gc.sessao()
+	req := ComandoRequest{
+		JogadorID:      sessao.JogadorID,
+		Token:          sessao.Token,
+		SequenceNumber: atomic.AddInt64(&gc.sequenceNumber, 1), // a mesma sequência dos movimentos
+		Comando:        comando,
+	}
+	if dados != nil {
+		bruto, err := json.Marshal(dados)
+		if err != nil {
+			return ResultadoComando{}, err
+		}
+		req.Dados = bruto
+	}
+
+	var resp ComandoResponse
+	if err := gc.client.Call("GameService.Comando", req, &resp); err != nil {
+		gc.verificarQueda(err)
+		return ResultadoComando{}, err
+	}
+	gc.aplicarPosicoes(resp.Posicoes)
+	gc.notificar()
+
+	if resp.Resultado.Erro != "" {
+		return resp.Resultado, errors.New(resp.Resultado.Erro)
+	}
+	return resp.Resultado, nil
+}
+
+// Derrota o inimigo do lado do jogador e devolve onde ele estava
+func (gc *GameClient) Interagir() (Ponto, error) {
+	var alvo Ponto
+	resultado, err := gc.Comando(ComandoInteragir, nil)
+	if err == nil {
+		err = json.Unmarshal(resultado.Resposta, &alvo)
+	}
+	return alvo, err
+}
+
+// Manda uma mensagem no chat
+func (gc *GameClient) Chat(texto string) error {
+	_, err := gc.Comando(ComandoChat, DadosChat{Texto: texto})
+	return err
+}
+
+// Mostra um emote em cima do jogador
+func (gc *GameClient) Emote(nome string) error {
+	_, err := gc.Comando(ComandoEmote, DadosEmote{Emote: nome})
+	return err
+}
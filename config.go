@@ -1,12 +1,38 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // essa struct guarda as configs de rede do jogo
 type NetworkConfig struct {
 	Host           string // ip ou nome do host (ex: localhost)
 	Port           string // porta usada pra conectar
 	DefaultMapFile string // nome do arq/mapa padrão
+
+	// TLS (opcional)
+	TLS         bool   // cliente: conecta usando TLS
+	CertFile    string // servidor: certificado em PEM (com KeyFile, liga o TLS)
+	KeyFile     string // servidor: chave privada em PEM
+	CAFile      string // cliente: CA extra pra verificar o servidor
+	Fingerprint string // cliente: SHA-256 fixo do certificado do servidor (pra autoassinados)
+
+	Senha string // cliente: senha do servidor, mandada no ConectarJogo
+
+	// gateway pra clientes que não são em Go (servidor; vazio = desligado)
+	PortaJSON string // JSON-RPC puro em TCP
+	PortaWeb  string // HTTP com o WebSocket em /ws
+
+	// perfil (opcional)
+	Nome    string // cliente: nome do jogador (vazio = gerado na hora)
+	Segredo string // cliente: segredo do perfil; com ele o servidor guarda as estatísticas
+
+	Espectador bool // cliente: entra só pra assistir, sem personagem
+
+	Coalescer time.Duration // cliente: junta os movimentos feitos nessa janela num lote só (0 = manda cada um na hora)
+
+	Rede RedeSimulada // cliente e servidor: atraso, banda e quedas simulados nas conexões RPC (desenvolvimento)
 }
 
 // multiplayer: utilizamos o ip de uma das maquinas
@@ -43,3 +69,55 @@ func NewConfig(host, port, mapFile string) NetworkConfig {
 		DefaultMapFile: mapFile,
 	}
 }
+
+// configurações da simulação do servidor
+type ConfigServidor struct {
+	TaxaTick          int           // ticks por segundo do loop do jogo
+	MovimentosPorTick int           // quantos movimentos de cada jogador são aplicados por tick
+	TamanhoFila       int           // quantos movimentos cada jogador pode deixar esperando
+	TempoInatividade  time.Duration // jogador sem nenhuma RPC por esse tempo é removido (0 = nunca)
+
+	MovimentosPorSegundo int   // limite de movimentos aceitos por segundo de cada jogador
+	RajadaMovimentos     int   // quantos movimentos seguidos podem passar do ritmo (ex: tecla segurada)
+	SaltoMaximoSequencia int64 // pulo de sequence number acima disso é anotado como suspeito
+	ExpulsarInfratores   bool  // derruba quem acumular LimiteInfracoes infrações
+	LimiteInfracoes      int   // infrações até a expulsão
+
+	Senha              string        // senha pra entrar no jogo (vazio = aberto)
+	SenhaAdmin         string        // senha que libera as RPCs de administração (vazio = desligado)
+	MaxTentativasSenha int           // senhas erradas por ip antes do bloqueio
+	BloqueioSenha      time.Duration // janela das tentativas e tempo de bloqueio
+
+	AvisoDesligamento time.Duration // contagem regressiva mostrada aos clientes antes de desligar
+
+	ArquivoEstado       string        // onde o estado do mundo é salvo e de onde é restaurado (vazio = não salva)
+	IntervaloSalvamento time.Duration // de quanto em quanto tempo salvar (além do desligamento)
+	ArquivoPerfis       string        // arquivo dos perfis e estatísticas dos jogadores (vazio = sem perfis)
+	ArquivoGravacao     string        // replay da partida em JSON lines (vazio = não grava)
+
+	PaginaWeb bool // serve o cliente do navegador na porta do gateway web (-porta-web)
+
+	Mapa []string // linhas do mapa distribuído aos clientes (vazio = cada cliente usa o seu)
+}
+
+// valores usados quando nada é informado
+var ConfigServidorPadrao = ConfigServidor{
+	TaxaTick:          20,
+	MovimentosPorTick: 1,
+	TamanhoFila:       32,
+	TempoInatividade:  2 * time.Minute,
+
+	MovimentosPorSegundo: 20,
+	RajadaMovimentos:     10,
+	SaltoMaximoSequencia: 100,
+	LimiteInfracoes:      200,
+
+	MaxTentativasSenha: 5,
+	BloqueioSenha:      time.Minute,
+
+	AvisoDesligamento: 5 * time.Second,
+
+	PaginaWeb: true,
+
+	IntervaloSalvamento: 30 * time.Second,
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"log"
+	"net/rpc"
+	"sync/atomic"
+	"time"
+)
+
+// erros do desligamento
+var (
+	ErrServidorDesligando = errors.New("o servidor está desligando")
+	ErrServidorDesligado  = errors.New("servidor desligado")
+)
+
+// quanto tempo esperar as RPCs em andamento terminarem antes de fechar as conexões
+const esperaRPCsEmAndamento = 5 * time.Second
+
+// Desliga o servidor com calma:
+//  1. para de aceitar conexões novas;
+//  2. avisa os clientes (motivo e contagem regressiva vão no snapshot);
+//  3. espera o tempo do aviso e as RPCs que ainda estão em andamento;
+//  4. fecha as conexões, que tiram seus jogadores do jogo;
+//  5. para o loop da simulação e salva o estado e os perfis (se estiverem ligados).
+//
+// Só roda uma vez; chamadas seguintes esperam o primeiro desligamento terminar.
+// Depois que Desligar volta o estado não muda mais.
+func (gs *GameServer) Desligar(motivo string, espera time.Duration) {
+	gs.desligar.Do(func() {
+		defer close(gs.desligado)
+
+		gs.mutexRede.Lock()
+		gs.fechado = true
+		for _, listener := range gs.listeners {
+			listener.Close()
+		}
+		gs.mutexRede.Unlock()
+
+		log.Printf("Desligando em %v: %s", espera, motivo)
+		gs.executar(func() {
+			gs.motivoDesligamento = motivo
+			gs.desligarEm = time.Now().Add(espera)
+		})
+		time.Sleep(espera)
+
+		// as RPCs chegam o tempo todo (os clientes sincronizam 10x por segundo),
+		// então espera um momento em que nenhuma esteja no meio do caminho
+		limite := time.Now().Add(esperaRPCsEmAndamento)
+		for gs.emAndamento.Load() > 0 && time.Now().Before(limite) {
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		gs.conexoes.Range(func(chave, _ any) bool {
+			chave.(*conexaoCliente).conn.Close()
+			return true
+		})
+		gs.atendendo.Wait() // cada conexão tira os seus jogadores usando o loop
+
+		close(gs.parar)
+		<-gs.parado
+		if err := gs.salvarTudo(); err != nil {
+			log.Println(err)
+		}
+		if gs.gravador != nil {
+			gs.gravador.fechar()
+			log.Println("Gravação salva em", gs.config.ArquivoGravacao)
+		}
+		log.Println("Servidor desligado")
+	})
+	<-gs.desligado
+}
+
+// Fechado quando o desligamento termina
+func (gs *GameServer) Desligado() <-chan struct{} {
+	return gs.desligado
+}
+
+// Começa o desligamento sem esperar (usado pelo console e pela RPC de administração)
+func (gs *GameServer) AgendarDesligamento(motivo string, espera time.Duration) error {
+	go gs.Desligar(motivo, espera)
+	return nil
+}
+
+// codec gob do net/rpc (igual ao padrão) que conta as RPCs lidas e ainda sem resposta
+type codecServidor struct {
+	rwc         io.ReadWriteCloser
+	dec         *gob.Decoder
+	enc         *gob.Encoder
+	encBuf      *bufio.Writer
+	emAndamento *atomic.Int64
+	fechado     bool
+}
+
+func novoCodecServidor(conn io.ReadWriteCloser, emAndamento *atomic.Int64) *codecServidor {
+	buf := bufio.NewWriter(conn)
+	return &codecServidor{
+		rwc:         conn,
+		dec:         gob.NewDecoder(conn),
+		enc:         gob.NewEncoder(buf),
+		encBuf:      buf,
+		emAndamento: emAndamento,
+	}
+}
+
+func (c *codecServidor) ReadRequestHeader(r *rpc.Request) error {
+	err := c.dec.Decode(r)
+	if err == nil {
+		c.emAndamento.Add(1) // toda requisição lida recebe exatamente uma resposta
+	}
+	return err
+}
+
+func (c *codecServidor) ReadRequestBody(body any) error {
+	return c.dec.Decode(body)
+}
+
+func (c *codecServidor) WriteResponse(r *rpc.Response, body any) (err error) {
+	defer c.emAndamento.Add(-1)
+
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			// não conseguiu codificar o cabeçalho: a conexão ficou inconsistente
+			log.Println("rpc: erro ao codificar cabeçalho da resposta:", err)
+			c.Close()
+		}
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			log.Println("rpc: erro ao codificar corpo da resposta:", err)
+			c.Close()
+		}
+		return
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *codecServidor) Close() error {
+	if c.fechado {
+		return nil
+	}
+	c.fechado = true
+	return c.rwc.Close()
+}
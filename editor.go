@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nsf/termbox-go"
+)
+
+// item da paleta do editor
+type itemPaleta struct {
+	Nome    string
+	Simbolo rune
+}
+
+// elementos que podem ser colocados no mapa pelo editor
+var PaletaEditor = []itemPaleta{
+	{"Parede", Parede.Simbolo},
+	{"Vegetação", Vegetacao.Simbolo},
+	{"Inimigo", Inimigo.Simbolo},
+	{"Spawn", SimboloSpawn},
+	{"Saída", Saida.Simbolo},
+	{"Borracha", Vazio.Simbolo},
+}
+
+// limite de passos guardados pra desfazer
+const maxHistoricoEditor = 200
+
+// estado do editor de mapas
+type Editor struct {
+	arquivo    string      // arquivo que está sendo editado
+	grade      gradeMapa   // mapa no formato texto (uma runa por célula)
+	cursorX    int         // posição x do cursor
+	cursorY    int         // posição y do cursor
+	pincel     int         // índice do item selecionado na paleta
+	desfazer   []gradeMapa // estados anteriores (undo)
+	refazer    []gradeMapa // estados desfeitos (redo)
+	modificado bool        // se tem alteração não salva
+	statusMsg  string      // mensagem mostrada embaixo do mapa
+	confirmar  bool        // ESC já foi apertado uma vez com alterações pendentes
+}
+
+// Cria o editor carregando o arquivo, ou um mapa vazio com borda se ele não existir (ou estiver vazio)
+func NovoEditor(arquivo string, largura, altura int) (*Editor, error) {
+	ed := &Editor{arquivo: arquivo}
+
+	linhas, err := LerLinhasMapa(arquivo)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, linha := range linhas {
+		ed.grade = append(ed.grade, []rune(linha))
+	}
+	ed.normalizar()
+	if len(ed.grade) > 0 && len(ed.grade[0]) > 0 {
+		ed.statusMsg = fmt.Sprintf("Editando %s", arquivo)
+		return ed, nil
+	}
+
+	if largura < 1 || altura < 1 {
+		return nil, fmt.Errorf("tamanho de mapa inválido: %dx%d", largura, altura)
+	}
+	ed.grade = novaGrade(largura, altura)
+	for y := 1; y < altura-1; y++ {
+		for x := 1; x < largura-1; x++ {
+			ed.grade[y][x] = Vazio.Simbolo
+		}
+	}
+	ed.modificado = true
+	ed.statusMsg = fmt.Sprintf("Novo mapa %s (%dx%d)", arquivo, largura, altura)
+	return ed, nil
+}
+
+// deixa todas as linhas com a mesma largura pra facilitar o redimensionamento
+func (ed *Editor) normalizar() {
+	largura := 0
+	for _, linha := range ed.grade {
+		largura = max(largura, len(linha))
+	}
+	for y, linha := range ed.grade {
+		for len(linha) < largura {
+			linha = append(linha, Vazio.Simbolo)
+		}
+		ed.grade[y] = linha
+	}
+}
+
+// guarda o estado atual antes de uma alteração
+func (ed *Editor) salvarHistorico() {
+	ed.desfazer = append(ed.desfazer, copiarGrade(ed.grade))
+	if len(ed.desfazer) > maxHistoricoEditor {
+		ed.desfazer = ed.desfazer[1:]
+	}
+	ed.refazer = nil
+	ed.modificado = true
+}
+
+// copia a grade pra que o histórico não seja alterado junto
+func copiarGrade(g gradeMapa) gradeMapa {
+	copia := make(gradeMapa, len(g))
+	for y, linha := range g {
+		copia[y] = append([]rune(nil), linha...)
+	}
+	return copia
+}
+
+// coloca o item da paleta selecionado embaixo do cursor
+func (ed *Editor) Pintar(simbolo rune) {
+	if ed.cursorY >= len(ed.grade) || ed.cursorX >= len(ed.grade[ed.cursorY]) {
+		return
+	}
+	if ed.grade[ed.cursorY][ed.cursorX] == simbolo {
+		return // nada muda, não precisa de histórico
+	}
+	ed.salvarHistorico()
+	ed.grade[ed.cursorY][ed.cursorX] = simbolo
+}
+
+// volta a última alteração
+func (ed *Editor) Desfazer() {
+	if len(ed.desfazer) == 0 {
+		ed.statusMsg = "Nada para desfazer"
+		return
+	}
+	ed.refazer = append(ed.refazer, ed.grade)
+	ed.grade = ed.desfazer[len(ed.desfazer)-1]
+	ed.desfazer = ed.desfazer[:len(ed.desfazer)-1]
+	ed.modificado = true
+	ed.limitarCursor()
+	ed.statusMsg = "Desfeito"
+}
+
+// refaz a última alteração desfeita
+func (ed *Editor) Refazer() {
+	if len(ed.refazer) == 0 {
+		ed.statusMsg = "Nada para refazer"
+		return
+	}
+	ed.desfazer = append(ed.desfazer, ed.grade)
+	ed.grade = ed.refazer[len(ed.refazer)-1]
+	ed.refazer = ed.refazer[:len(ed.refazer)-1]
+	ed.modificado = true
+	ed.limitarCursor()
+	ed.statusMsg = "Refeito"
+}
+
+// muda o tamanho do mapa em dx colunas e dy linhas (novas células ficam vazias)
+func (ed *Editor) Redimensionar(dx, dy int) {
+	largura, altura := len(ed.grade[0])+dx, len(ed.grade)+dy
+	if largura < 1 || altura < 1 {
+		ed.statusMsg = "O mapa precisa ter pelo menos uma célula"
+		return
+	}
+
+	ed.salvarHistorico()
+	nova := make(gradeMapa, altura)
+	for y := range nova {
+		nova[y] = make([]rune, largura)
+		for x := range nova[y] {
+			nova[y][x] = Vazio.Simbolo
+			if y < len(ed.grade) && x < len(ed.grade[y]) {
+				nova[y][x] = ed.grade[y][x]
+			}
+		}
+	}
+	ed.grade = nova
+	ed.limitarCursor()
+	ed.statusMsg = fmt.Sprintf("Mapa redimensionado para %dx%d", largura, altura)
+}
+
+// mantém o cursor dentro do mapa
+func (ed *Editor) limitarCursor() {
+	ed.cursorY = max(0, min(ed.cursorY, len(ed.grade)-1))
+	ed.cursorX = max(0, min(ed.cursorX, len(ed.grade[ed.cursorY])-1))
+}
+
+// grava o mapa no arquivo no formato texto
+func (ed *Editor) Salvar() error {
+	linhas := make([]string, len(ed.grade))
+	for y, linha := range ed.grade {
+		linhas[y] = string(linha)
+	}
+	if err := SalvarMapa(ed.arquivo, linhas); err != nil {
+		return err
+	}
+	ed.modificado = false
+	ed.statusMsg = fmt.Sprintf("Mapa salvo em %s", ed.arquivo)
+	return nil
+}
+
+// trata uma tecla; devolve false quando o editor deve fechar
+func (ed *Editor) ProcessarTecla(ev termbox.Event) bool {
+	if ev.Key != termbox.KeyEsc {
+		ed.confirmar = false
+	}
+
+	switch {
+	case ev.Key == termbox.KeyEsc:
+		if ed.modificado && !ed.confirmar {
+			ed.confirmar = true
+			ed.statusMsg = "Alterações não salvas! ESC de novo para sair sem salvar"
+			return true
+		}
+		return false
+	case ev.Key == termbox.KeyArrowUp:
+		ed.cursorY--
+	case ev.Key == termbox.KeyArrowDown:
+		ed.cursorY++
+	case ev.Key == termbox.KeyArrowLeft:
+		ed.cursorX--
+	case ev.Key == termbox.KeyArrowRight:
+		ed.cursorX++
+	case ev.Key == termbox.KeySpace || ev.Key == termbox.KeyEnter:
+		ed.Pintar(PaletaEditor[ed.pincel].Simbolo)
+	case ev.Key == termbox.KeyDelete || ev.Ch == 'x' || ev.Ch == 'X':
+		ed.Pintar(Vazio.Simbolo)
+	case ev.Key == termbox.KeyTab:
+		ed.pincel = (ed.pincel + 1) % len(PaletaEditor)
+	case ev.Ch >= '1' && int(ev.Ch-'1') < len(PaletaEditor):
+		ed.pincel = int(ev.Ch - '1')
+	case ev.Key == termbox.KeyCtrlZ || ev.Ch == 'u' || ev.Ch == 'U':
+		ed.Desfazer()
+	case ev.Key == termbox.KeyCtrlY || ev.Ch == 'r' || ev.Ch == 'R':
+		ed.Refazer()
+	case ev.Ch == ']':
+		ed.Redimensionar(1, 0)
+	case ev.Ch == '[':
+		ed.Redimensionar(-1, 0)
+	case ev.Ch == '}':
+		ed.Redimensionar(0, 1)
+	case ev.Ch == '{':
+		ed.Redimensionar(0, -1)
+	case ev.Key == termbox.KeyCtrlS || ev.Ch == 's' || ev.Ch == 'S':
+		if err := ed.Salvar(); err != nil {
+			ed.statusMsg = "Erro ao salvar: " + err.Error()
+		}
+	}
+
+	ed.limitarCursor()
+	return true
+}
+
+// desenha o mapa, o cursor, a paleta e as instruções
+func (ed *Editor) Desenhar() {
+	termbox.Clear(CorPadrao, CorPadrao)
+
+	for y, linha := range ed.grade {
+		for x, ch := range linha {
+			elem := elementoEditor(ch)
+			termbox.SetCell(x, y, elem.Simbolo, elem.Cor, elem.CorFundo)
+		}
+	}
+
+	// cursor: mostra o elemento embaixo com as cores invertidas
+	if ed.cursorY < len(ed.grade) && ed.cursorX < len(ed.grade[ed.cursorY]) {
+		elem := elementoEditor(ed.grade[ed.cursorY][ed.cursorX])
+		termbox.SetCell(ed.cursorX, ed.cursorY, elem.Simbolo, elem.Cor|termbox.AttrReverse, elem.CorFundo)
+		termbox.SetCursor(ed.cursorX, ed.cursorY)
+	}
+
+	y := len(ed.grade) + 1
+	escreverTexto(0, y, ed.statusMsg, CorTexto)
+
+	// paleta com o item atual destacado
+	x := 0
+	for i, item := range PaletaEditor {
+		texto := fmt.Sprintf("%d:%s %c  ", i+1, item.Nome, elementoEditor(item.Simbolo).Simbolo)
+		cor := CorTexto
+		if i == ed.pincel {
+			cor = CorBranco | termbox.AttrBold | termbox.AttrReverse
+		}
+		escreverTexto(x, y+2, texto, cor)
+		x += len([]rune(texto))
+	}
+
+	pos := fmt.Sprintf("(%d, %d)  %dx%d", ed.cursorX, ed.cursorY, len(ed.grade[0]), len(ed.grade))
+	if ed.modificado {
+		pos += "  [modificado]"
+	}
+	escreverTexto(0, y+3, pos, CorTexto)
+	escreverTexto(0, y+4, "Setas movem. Espaço coloca, X apaga, 1-6/Tab troca. U/R desfaz/refaz.", CorTexto)
+	escreverTexto(0, y+5, "[ ] colunas, { } linhas. S salva. ESC sai.", CorTexto)
+
+	termbox.Flush()
+}
+
+// elemento usado pra desenhar uma runa do arquivo no editor
+func elementoEditor(ch rune) Elemento {
+	if ch == SimboloSpawn {
+		return Personagem
+	}
+	elem := ElementoDoSimbolo(ch)
+	if elem == Vazio && ch != Vazio.Simbolo {
+		// símbolo desconhecido: mostra como está pra não sumir do mapa
+		elem.Simbolo = ch
+	}
+	return elem
+}
+
+// escreve um texto numa linha da tela
+func escreverTexto(x, y int, texto string, cor Cor) {
+	for i, c := range []rune(texto) {
+		termbox.SetCell(x+i, y, c, cor, CorPadrao)
+	}
+}
+
+// roda o editor de mapas no terminal até o usuário sair
+func runEditor(arquivo string, largura, altura int) error {
+	ed, err := NovoEditor(arquivo, largura, altura)
+	if err != nil {
+		return err
+	}
+
+	IniciarInterface()
+	defer FinalizarInterface()
+
+	for {
+		ed.Desenhar()
+		ev := termbox.PollEvent()
+		if ev.Type != termbox.EventKey {
+			continue
+		}
+		if !ed.ProcessarTecla(ev) {
+			return nil
+		}
+	}
+}
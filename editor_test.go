@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestNovoEditor(t *testing.T) {
+	dir := t.TempDir()
+	vazio := filepath.Join(dir, "vazio.txt")
+	if err := os.WriteFile(vazio, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	existente := filepath.Join(dir, "existente.txt")
+	if err := os.WriteFile(existente, []byte("▤▤▤\n▤ ▤▤\n▤▤▤\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nome            string
+		arquivo         string
+		largura, altura int
+		erro            bool
+		esperado        []string // nil = não confere a grade
+	}{
+		{nome: "arquivo novo", arquivo: filepath.Join(dir, "novo.txt"), largura: 4, altura: 3, esperado: []string{"▤▤▤▤", "▤  ▤", "▤▤▤▤"}},
+		{nome: "arquivo vazio vira mapa novo", arquivo: vazio, largura: 3, altura: 3, esperado: []string{"▤▤▤", "▤ ▤", "▤▤▤"}},
+		{nome: "arquivo existente ignora o tamanho", arquivo: existente, largura: 0, altura: 0, esperado: []string{"▤▤▤ ", "▤ ▤▤", "▤▤▤ "}},
+		{nome: "largura zero", arquivo: filepath.Join(dir, "a.txt"), largura: 0, altura: 5, erro: true},
+		{nome: "altura negativa", arquivo: filepath.Join(dir, "b.txt"), largura: 5, altura: -1, erro: true},
+		{nome: "arquivo vazio sem tamanho", arquivo: vazio, largura: 0, altura: 0, erro: true},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			ed, err := NovoEditor(c.arquivo, c.largura, c.altura)
+			if c.erro {
+				if err == nil {
+					t.Fatalf("esperava erro, veio uma grade %dx%d", len(ed.grade[0]), len(ed.grade))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if len(ed.grade) != len(c.esperado) {
+				t.Fatalf("grade com %d linhas, esperava %d", len(ed.grade), len(c.esperado))
+			}
+			for y, linha := range ed.grade {
+				if string(linha) != c.esperado[y] {
+					t.Errorf("linha %d = %q, esperava %q", y, string(linha), c.esperado[y])
+				}
+			}
+		})
+	}
+}
+
+func TestEditorTeclasMaiusculas(t *testing.T) {
+	for _, teclas := range []string{"xurs", "XURS"} {
+		t.Run(teclas, func(t *testing.T) {
+			arquivo := filepath.Join(t.TempDir(), "mapa.txt")
+			ed, err := NovoEditor(arquivo, 3, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			apagar, desfazer, refazer, salvar := []rune(teclas)[0], []rune(teclas)[1], []rune(teclas)[2], []rune(teclas)[3]
+
+			// o cursor começa na parede do canto
+			ed.ProcessarTecla(termbox.Event{Type: termbox.EventKey, Ch: apagar})
+			if ed.grade[0][0] != Vazio.Simbolo {
+				t.Fatalf("%c não apagou a célula", apagar)
+			}
+			ed.ProcessarTecla(termbox.Event{Type: termbox.EventKey, Ch: desfazer})
+			if ed.grade[0][0] != Parede.Simbolo {
+				t.Fatalf("%c não desfez", desfazer)
+			}
+			ed.ProcessarTecla(termbox.Event{Type: termbox.EventKey, Ch: refazer})
+			if ed.grade[0][0] != Vazio.Simbolo {
+				t.Fatalf("%c não refez", refazer)
+			}
+			ed.ProcessarTecla(termbox.Event{Type: termbox.EventKey, Ch: salvar})
+			if ed.modificado {
+				t.Fatalf("%c não salvou: %s", salvar, ed.statusMsg)
+			}
+			if _, err := os.Stat(arquivo); err != nil {
+				t.Fatalf("%c não gravou o arquivo: %v", salvar, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// espectador tentando fazer o que só jogador faz
+var ErrEspectador = errors.New("espectadores não podem jogar")
+
+// instruções mostradas no lugar das do jogador
+const instrucoesEspectador = "Espectador: A/D troca o jogador seguido, M mostra o mapa inteiro. / para comandos. ESC para sair."
+
+// Entra como espectador: recebe as atualizações do mundo, mas não tem personagem,
+// não ocupa célula, não conta na lista de jogadores nem na escolha de cores
+func (gs *GameService) conectarEspectador(req ConectarRequest, reply *ConectarPosicaoResponse) error {
+	id := uuid.New().String()
+	token := gerarToken()
+	gs.conexao.adicionarEspectador(id, token)
+	gs.servidor.espectadores.Store(id, req.Nome)
+
+	reply.JogadorID = id
+	reply.Token = token
+	reply.Posicoes = gs.servidor.posicoesPara(id)
+	reply.Mapa = gs.servidor.snapshot.Load().mapa
+
+	log.Printf("Espectador %s conectado (%s)", req.Nome, gs.conexao.endereco)
+	return nil
+}
+
+// esquece um espectador que saiu (devolve false se o id não era de espectador)
+func (gs *GameServer) removerEspectador(id string) bool {
+	nome, existe := gs.espectadores.LoadAndDelete(id)
+	if !existe {
+		return false
+	}
+	gs.atividade.Delete(id)
+	log.Printf("Espectador %s saiu", nome)
+	return true
+}
+
+// quantos espectadores estão assistindo
+func (gs *GameServer) contarEspectadores() int {
+	total := 0
+	gs.espectadores.Range(func(_, _ any) bool {
+		total++
+		return true
+	})
+	return total
+}
+
+// Teclas do espectador: A/D trocam o jogador seguido, M volta pro mapa inteiro
+func (gc *GameClient) ProcessarEventoEspectador(evento EventoTeclado) {
+	gm := gc.gameManager
+	switch {
+	case evento.Tipo == "marcar":
+		gm.VerMapaInteiro()
+	case evento.Tipo == "mover" && (evento.Tecla == 'd' || evento.Tecla == 'D'):
+		gm.SeguirProximo(1)
+	case evento.Tipo == "mover" && (evento.Tecla == 'a' || evento.Tecla == 'A'):
+		gm.SeguirProximo(-1)
+	default:
+		return
+	}
+	gc.notificar()
+}
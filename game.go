@@ -3,7 +3,10 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
@@ -15,6 +18,9 @@ type GameManager struct {
 	jogadorID           string                  // ID do jogador local
 	jogadoresRemotos    map[string]PosicaoJogador // Jogadores remotos
 	comandosProcessados map[string]int64        // jogadorID -> último sequence number processado
+	reposicaoLocal      int64                   // último Reposicao do jogador local aplicado
+	espectador          bool                    // sem jogador local: só assiste
+	seguindo            string                  // jogador que a câmera do espectador segue (vazio = mapa inteiro)
 	mutex               sync.RWMutex
 }
 
@@ -51,6 +57,30 @@ func (gm *GameManager) InicializarJogo(mapaFile string) error {
 	return nil
 }
 
+// Inicializa o jogo local com o mapa recebido do servidor
+func (gm *GameManager) InicializarJogoComLinhas(linhas []string) error {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	if gm.jogo != nil {
+		return nil // Jogo já inicializado
+	}
+
+	jogo := &Jogo{
+		ID:             uuid.New().String(),
+		Jogadores:      make(map[string]*Jogador),
+		UltimoVisitado: Vazio,
+		StatusMsg:      "Jogo multiplayer iniciado",
+	}
+
+	if err := CarregarMapaDeLinhas(linhas, jogo); err != nil {
+		return fmt.Errorf("erro ao carregar mapa do servidor: %v", err)
+	}
+
+	gm.jogo = jogo
+	return nil
+}
+
 // Cria um jogador local com as informações recebidas do servidor
 func (gm *GameManager) CriarJogadorLocal(jogadorID string, nome string, posX, posY int, cor Cor) *Jogador {
 	gm.mutex.Lock()
@@ -92,10 +122,25 @@ func (gm *GameManager) AtualizarJogadoresRemotos(posicoes map[string]PosicaoJoga
 		return
 	}
 
+	// Quem não veio nas posições saiu do jogo
+	for id := range gm.jogo.Jogadores {
+		if _, existe := posicoes[id]; !existe && id != gm.jogadorID {
+			delete(gm.jogo.Jogadores, id)
+		}
+	}
+
 	// Atualiza os jogadores no jogo local
 	for id, posicao := range posicoes {
-		// Não atualiza o jogador local
+		// Não atualiza o jogador local (ele é previsto aqui), a não ser que o
+		// servidor tenha movido ele à força (teleporte, troca de mapa)
 		if id == gm.jogadorID {
+			if local, existe := gm.jogo.Jogadores[id]; existe && posicao.Reposicao != gm.reposicaoLocal {
+				local.PosX, local.PosY = posicao.PosX, posicao.PosY
+				gm.reposicaoLocal = posicao.Reposicao
+			}
+			if local, existe := gm.jogo.Jogadores[id]; existe {
+				local.Emote = SimbolosEmote[posicao.Emote]
+			}
 			continue
 		}
 
@@ -105,7 +150,7 @@ func (gm *GameManager) AtualizarJogadoresRemotos(posicoes map[string]PosicaoJoga
 			jogador = &Jogador{
 				ID:        posicao.ID,
 				Nome:      posicao.Nome,
-				Cor:       posicao.Cor,
+				Cor:       posicao.Cor.Terminal(),
 				Simbolo:   posicao.Simbolo,
 				Conectado: posicao.Conectado,
 			}
@@ -116,6 +161,7 @@ func (gm *GameManager) AtualizarJogadoresRemotos(posicoes map[string]PosicaoJoga
 		jogador.PosX = posicao.PosX
 		jogador.PosY = posicao.PosY
 		jogador.Conectado = posicao.Conectado
+		jogador.Emote = SimbolosEmote[posicao.Emote]
 	}
 }
 
@@ -185,6 +231,147 @@ func (gm *GameManager) podeMover(x, y int, jogadorID string) bool {
 	return true
 }
 
+// Troca a mensagem de status mostrada na tela
+func (gm *GameManager) DefinirStatus(msg string) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	if gm.jogo != nil {
+		gm.jogo.StatusMsg = msg
+	}
+}
+
+// Troca o aviso da administração mostrado na tela
+func (gm *GameManager) DefinirAviso(msg string) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	if gm.jogo != nil {
+		gm.jogo.Aviso = msg
+	}
+}
+
+// Mostra as últimas mensagens de chat que vieram do servidor
+func (gm *GameManager) DefinirChat(mensagens []MensagemChat) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	if gm.jogo == nil {
+		return
+	}
+	linhas := make([]string, 0, len(mensagens)) // fatia nova: a antiga pode estar num EstadoJogo
+	for _, msg := range mensagens {
+		linhas = append(linhas, msg.Nome+": "+msg.Texto)
+	}
+	gm.jogo.Chat = linhas
+}
+
+// Troca o mapa do jogo local pelo que o servidor mandou (os jogadores ficam)
+func (gm *GameManager) TrocarMapa(linhas []string) error {
+	novo := &Jogo{}
+	if err := CarregarMapaDeLinhas(linhas, novo); err != nil {
+		return fmt.Errorf("erro ao carregar mapa do servidor: %v", err)
+	}
+
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	if gm.jogo == nil {
+		return fmt.Errorf("jogo não inicializado")
+	}
+	gm.jogo.Mapa = novo.Mapa
+	gm.jogo.Spawns = novo.Spawns
+	gm.jogo.StatusMsg = "O servidor atualizou o mapa"
+	return nil
+}
+
+// Liga o modo espectador: não há jogador local e a câmera começa no mapa inteiro
+func (gm *GameManager) IniciarEspectador() {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	gm.espectador = true
+	gm.seguindo = ""
+	if gm.jogo != nil {
+		gm.jogo.StatusMsg = "Assistindo: mapa inteiro"
+	}
+}
+
+// Passa a câmera pro próximo jogador (passo 1) ou pro anterior (passo -1), em ordem de nome
+func (gm *GameManager) SeguirProximo(passo int) {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	if gm.jogo == nil {
+		return
+	}
+	ids := make([]string, 0, len(gm.jogo.Jogadores))
+	for id, jogador := range gm.jogo.Jogadores {
+		if jogador.Conectado {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		gm.seguindo = ""
+		gm.jogo.StatusMsg = "Ninguém jogando pra seguir"
+		return
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := gm.jogo.Jogadores[ids[i]], gm.jogo.Jogadores[ids[j]]
+		if a.Nome != b.Nome {
+			return a.Nome < b.Nome
+		}
+		return a.ID < b.ID
+	})
+
+	// sem ninguém seguido, o primeiro passo cai no primeiro (ou no último, pra trás)
+	atual := -1
+	if passo < 0 {
+		atual = 0
+	}
+	for i, id := range ids {
+		if id == gm.seguindo {
+			atual = i
+		}
+	}
+	proximo := ((atual+passo)%len(ids) + len(ids)) % len(ids)
+	gm.seguindo = ids[proximo]
+	gm.jogo.StatusMsg = "Seguindo " + gm.jogo.Jogadores[gm.seguindo].Nome
+}
+
+// Volta a câmera do espectador pro mapa inteiro
+func (gm *GameManager) VerMapaInteiro() {
+	gm.mutex.Lock()
+	defer gm.mutex.Unlock()
+
+	gm.seguindo = ""
+	if gm.jogo != nil {
+		gm.jogo.StatusMsg = "Assistindo: mapa inteiro"
+	}
+}
+
+// Posição atual do jogador local
+func (gm *GameManager) PosicaoLocal() (Ponto, bool) {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+
+	if gm.jogo == nil {
+		return Ponto{}, false
+	}
+	jogador, existe := gm.jogo.Jogadores[gm.jogadorID]
+	if !existe {
+		return Ponto{}, false
+	}
+	return Ponto{jogador.PosX, jogador.PosY}, true
+}
+
+// Verifica (com lock) se o jogador local pode entrar na posição
+func (gm *GameManager) PodeMoverLocal(p Ponto) bool {
+	gm.mutex.RLock()
+	defer gm.mutex.RUnlock()
+	return gm.podeMover(p.X, p.Y, gm.jogadorID)
+}
+
 // Obtém o estado atual do jogo local
 func (gm *GameManager) ObterEstado() *EstadoJogo {
 	gm.mutex.RLock()
@@ -201,11 +388,24 @@ func (gm *GameManager) obterEstadoAtual() *EstadoJogo {
 		}
 	}
 
-	return &EstadoJogo{
+	estado := &EstadoJogo{
 		Mapa:      gm.jogo.Mapa,
 		Jogadores: gm.copiarJogadores(),
 		StatusMsg: gm.jogo.StatusMsg,
+		Aviso:     gm.jogo.Aviso,
+		Chat:      gm.jogo.Chat,
 	}
+
+	// o espectador vê o mapa inteiro ou acompanha quem escolheu
+	if gm.espectador {
+		estado.Instrucoes = instrucoesEspectador
+		if seguido, existe := gm.jogo.Jogadores[gm.seguindo]; existe && seguido.Conectado {
+			estado.Camera = &Ponto{seguido.PosX, seguido.PosY}
+		} else if gm.seguindo != "" {
+			estado.StatusMsg = "O jogador seguido saiu: mapa inteiro"
+		}
+	}
+	return estado
 }
 
 // Copia os jogadores para evitar problemas de concorrência
@@ -221,6 +421,7 @@ func (gm *GameManager) copiarJogadores() map[string]*Jogador {
 				Cor:       jogador.Cor,
 				Simbolo:   jogador.Simbolo,
 				Conectado: jogador.Conectado,
+				Emote:     jogador.Emote,
 			}
 		}
 	}
@@ -235,25 +436,57 @@ func CarregarMapa(nome string, jogo *Jogo) error {
 	}
 	defer arq.Close()
 
-	scanner := bufio.NewScanner(arq)
+	return LerMapa(arq, jogo)
+}
+
+// Carrega o mapa a partir das linhas de texto (ex: mapa enviado pelo servidor)
+func CarregarMapaDeLinhas(linhas []string, jogo *Jogo) error {
+	return LerMapa(strings.NewReader(strings.Join(linhas, "\n")), jogo)
+}
+
+// Lê o mapa no formato texto e preenche o jogo
+func LerMapa(r io.Reader, jogo *Jogo) error {
+	scanner := bufio.NewScanner(r)
 	y := 0
 	for scanner.Scan() {
 		linha := scanner.Text()
 		var linhaElems []Elemento
+		x := 0
 		for _, ch := range linha {
-			e := Vazio
-			switch ch {
-			case '▤':
-				e = Parede
-			case '☠':
-				e = Inimigo
-			case '♣':
-				e = Vegetacao
+			if ch == SimboloSpawn {
+				// ponto de nascimento: fica vazio no mapa mas é guardado
+				jogo.Spawns = append(jogo.Spawns, Ponto{x, y})
 			}
-			linhaElems = append(linhaElems, e)
+			linhaElems = append(linhaElems, ElementoDoSimbolo(ch))
+			x++
 		}
 		jogo.Mapa = append(jogo.Mapa, linhaElems)
 		y++
 	}
 	return scanner.Err()
 }
+
+// Traduz um símbolo do arquivo de mapa no elemento correspondente
+func ElementoDoSimbolo(ch rune) Elemento {
+	switch ch {
+	case '▤':
+		return Parede
+	case '☠':
+		return Inimigo
+	case '♣':
+		return Vegetacao
+	case '⚑':
+		return Saida
+	}
+	return Vazio
+}
+
+// Lê as linhas de um arquivo de mapa sem interpretar os elementos
+func LerLinhasMapa(nome string) ([]string, error) {
+	dados, err := os.ReadFile(nome)
+	if err != nil {
+		return nil, err
+	}
+	texto := strings.TrimRight(string(dados), "\n")
+	return strings.Split(texto, "\n"), nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync/atomic"
+	"time"
+)
+
+// Gateway pra clientes que não são deste repositório: os mesmos métodos do
+// GameService em JSON-RPC 1.0 (numa porta TCP própria e por WebSocket) e,
+// no WebSocket, as atualizações do mundo chegando sozinhas a cada tick.
+// O formato das mensagens está em PROTOCOLO.md.
+
+// cliente do navegador, servido na porta web
+//
+//go:embed web
+var arquivosWeb embed.FS
+
+// nome do método das atualizações empurradas pelo WebSocket
+const metodoAtualizacao = "atualizacao"
+
+// notificação JSON-RPC 1.0 (id nulo: não espera resposta)
+type notificacaoJSON struct {
+	Method string `json:"method"`
+	Params []any  `json:"params"`
+	ID     any    `json:"id"`
+}
+
+// Atende JSON-RPC na porta da config (uma mensagem JSON depois da outra, sem separador obrigatório)
+func (gs *GameServer) StartJSONRPC(config NetworkConfig) error {
+	listener, err := gs.escutar(config)
+	if err != nil {
+		return err
+	}
+	log.Printf("Gateway JSON-RPC iniciado na porta %s", config.Port)
+
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return ErrServidorDesligado
+		}
+		if err != nil {
+			log.Printf("Erro ao aceitar conexão JSON: %v", err)
+			continue
+		}
+		conn = config.Rede.Embrulhar(conn)
+		gs.atendendo.Add(1)
+		go gs.atenderConexaoCom(novaConexaoCliente(conn), contarEmAndamento(jsonrpc.NewServerCodec(conn), &gs.emAndamento))
+	}
+}
+
+// Atende HTTP na porta da config: /ws é o WebSocket com JSON-RPC e atualizações
+// e, se a config pedir, / é o cliente do navegador
+func (gs *GameServer) StartWeb(config NetworkConfig) error {
+	rotas := http.NewServeMux()
+	rotas.HandleFunc("/ws", gs.atenderWebSocket)
+	if gs.config.PaginaWeb {
+		paginas, err := fs.Sub(arquivosWeb, "web")
+		if err != nil {
+			return err
+		}
+		rotas.Handle("/", http.FileServerFS(paginas))
+	}
+
+	listener, err := gs.escutar(config)
+	if err != nil {
+		return err
+	}
+	if gs.config.PaginaWeb {
+		log.Printf("Gateway WebSocket iniciado na porta %s (/ws), com o cliente do navegador em /", config.Port)
+	} else {
+		log.Printf("Gateway WebSocket iniciado na porta %s (/ws)", config.Port)
+	}
+
+	servidor := &http.Server{Handler: rotas, ReadHeaderTimeout: 10 * time.Second}
+
+	err = servidor.Serve(listener)
+	if errors.Is(err, net.ErrClosed) {
+		return ErrServidorDesligado
+	}
+	return err
+}
+
+// uma conexão WebSocket: JSON-RPC nos dois sentidos e atualizações empurradas
+func (gs *GameServer) atenderWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := aceitarWebSocket(w, r)
+	if err != nil {
+		return
+	}
+
+	conexao := novaConexaoCliente(ws)
+	fim := make(chan struct{})
+	go gs.transmitirAtualizacoes(conexao, ws, fim)
+	defer close(fim)
+
+	gs.atendendo.Add(1)
+	gs.atenderConexaoCom(conexao, contarEmAndamento(jsonrpc.NewServerCodec(ws), &gs.emAndamento))
+}
+
+// empurra as posições pra cada jogador (ou espectador) da conexão sempre que sai um snapshot novo
+func (gs *GameServer) transmitirAtualizacoes(conexao *conexaoCliente, ws *conexaoWebSocket, fim <-chan struct{}) {
+	ticker := time.NewTicker(time.Second / time.Duration(gs.config.TaxaTick))
+	defer ticker.Stop()
+
+	var ultimo *snapshotServidor
+	for {
+		select {
+		case <-fim:
+			return
+		case <-ticker.C:
+		}
+
+		snap := gs.snapshot.Load()
+		ids := conexao.idsJogadores()
+		if snap == ultimo || len(ids) == 0 {
+			continue // nada novo, ou ainda não entrou no jogo
+		}
+		ultimo = snap
+
+		for _, id := range ids {
+			dados, err := json.Marshal(notificacaoJSON{Method: metodoAtualizacao, Params: []any{gs.posicoesPara(id)}})
+			if err != nil {
+				log.Println("Erro ao codificar atualização:", err)
+				return
+			}
+			if _, err := ws.Write(dados); err != nil {
+				return // a conexão caiu: o atenderConexaoCom tira os jogadores
+			}
+			gs.registrarAtividade(id) // quem recebe as atualizações está presente, mesmo sem chamar nada
+		}
+	}
+}
+
+// codec que conta as RPCs lidas e ainda sem resposta, pro desligamento esperar por elas
+type codecContador struct {
+	rpc.ServerCodec
+	emAndamento *atomic.Int64
+}
+
+func contarEmAndamento(codec rpc.ServerCodec, emAndamento *atomic.Int64) rpc.ServerCodec {
+	return &codecContador{ServerCodec: codec, emAndamento: emAndamento}
+}
+
+func (c *codecContador) ReadRequestHeader(r *rpc.Request) error {
+	err := c.ServerCodec.ReadRequestHeader(r)
+	if err == nil {
+		c.emAndamento.Add(1)
+	}
+	return err
+}
+
+func (c *codecContador) WriteResponse(r *rpc.Response, body any) error {
+	defer c.emAndamento.Add(-1)
+	return c.ServerCodec.WriteResponse(r, body)
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"slices"
+	"strings"
+)
+
+// tipos de mapa que o gerador sabe criar
+const (
+	GeradorLabirinto = "labirinto" // labirinto perfeito (um caminho entre quaisquer duas células)
+	GeradorMasmorra  = "masmorra"  // salas retangulares ligadas por corredores
+)
+
+// configuração usada pra gerar um mapa
+type ConfigGerador struct {
+	Tipo      string // labirinto ou masmorra
+	Largura   int    // largura do mapa em células
+	Altura    int    // altura do mapa em células
+	Seed      int64  // semente do gerador (mesma seed = mesmo mapa)
+	Spawns    int    // quantos pontos de nascimento colocar
+	Inimigos  int    // quantos inimigos colocar
+	Vegetacao int    // quantas plantas colocar
+}
+
+// config padrão pra quando o usuário só escolhe o tipo
+var ConfigGeradorPadrao = ConfigGerador{
+	Tipo:      GeradorMasmorra,
+	Largura:   80,
+	Altura:    30,
+	Seed:      1,
+	Spawns:    8,
+	Inimigos:  10,
+	Vegetacao: 40,
+}
+
+// grade de runas usada durante a geração (mesmo formato do arquivo de mapa)
+type gradeMapa [][]rune
+
+// gera um mapa e devolve as linhas no formato lido por CarregarMapa
+func GerarMapa(cfg ConfigGerador) ([]string, error) {
+	if cfg.Largura < 7 || cfg.Altura < 7 {
+		return nil, fmt.Errorf("mapa muito pequeno: mínimo 7x7, pedido %dx%d", cfg.Largura, cfg.Altura)
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	grade := novaGrade(cfg.Largura, cfg.Altura)
+
+	switch cfg.Tipo {
+	case GeradorLabirinto:
+		cavarLabirinto(grade, rng)
+	case GeradorMasmorra:
+		cavarMasmorra(grade, rng)
+	default:
+		return nil, fmt.Errorf("tipo de mapa desconhecido: %q", cfg.Tipo)
+	}
+
+	if err := povoarMapa(grade, cfg, rng); err != nil {
+		return nil, err
+	}
+
+	linhas := make([]string, len(grade))
+	for y, linha := range grade {
+		linhas[y] = string(linha)
+	}
+	return linhas, nil
+}
+
+// salva as linhas do mapa num arquivo texto
+func SalvarMapa(nome string, linhas []string) error {
+	return os.WriteFile(nome, []byte(strings.Join(linhas, "\n")+"\n"), 0644)
+}
+
+// cria uma grade toda preenchida com parede
+func novaGrade(largura, altura int) gradeMapa {
+	grade := make(gradeMapa, altura)
+	for y := range grade {
+		grade[y] = []rune(strings.Repeat(string(Parede.Simbolo), largura))
+	}
+	return grade
+}
+
+// verifica se (x, y) é uma célula que pode ser cavada (não encosta na borda)
+func (g gradeMapa) interna(x, y int) bool {
+	return y > 0 && y < len(g)-1 && x > 0 && x < len(g[y])-1
+}
+
+// labirinto com backtracking iterativo sobre as células ímpares
+func cavarLabirinto(g gradeMapa, rng *rand.Rand) {
+	direcoes := []Ponto{{0, -2}, {2, 0}, {0, 2}, {-2, 0}}
+
+	g[1][1] = Vazio.Simbolo
+	pilha := []Ponto{{1, 1}}
+	for len(pilha) > 0 {
+		atual := pilha[len(pilha)-1]
+
+		// procura vizinhos ainda não cavados em ordem aleatória
+		var vizinhos []Ponto
+		for _, d := range direcoes {
+			nx, ny := atual.X+d.X, atual.Y+d.Y
+			if g.interna(nx, ny) && g[ny][nx] == Parede.Simbolo {
+				vizinhos = append(vizinhos, Ponto{nx, ny})
+			}
+		}
+		if len(vizinhos) == 0 {
+			pilha = pilha[:len(pilha)-1]
+			continue
+		}
+
+		prox := vizinhos[rng.Intn(len(vizinhos))]
+		g[(atual.Y+prox.Y)/2][(atual.X+prox.X)/2] = Vazio.Simbolo // derruba a parede do meio
+		g[prox.Y][prox.X] = Vazio.Simbolo
+		pilha = append(pilha, prox)
+	}
+}
+
+// masmorra: salas aleatórias sem sobreposição ligadas em sequência por corredores em L
+func cavarMasmorra(g gradeMapa, rng *rand.Rand) {
+	largura, altura := len(g[0]), len(g)
+
+	type sala struct{ x, y, l, a int }
+	sobrepoe := func(s, o sala) bool {
+		// deixa pelo menos uma parede entre as salas
+		return s.x-1 < o.x+o.l && o.x-1 < s.x+s.l && s.y-1 < o.y+o.a && o.y-1 < s.y+s.a
+	}
+
+	var salas []sala
+	maxSalas := max(2, largura*altura/150)
+	for tentativa := 0; tentativa < maxSalas*10 && len(salas) < maxSalas; tentativa++ {
+		l := 3 + rng.Intn(max(1, min(10, largura/4)))
+		a := 3 + rng.Intn(max(1, min(6, altura/4)))
+		if l > largura-2 || a > altura-2 {
+			continue
+		}
+		nova := sala{1 + rng.Intn(largura-l-1), 1 + rng.Intn(altura-a-1), l, a}
+
+		livre := true
+		for _, s := range salas {
+			if sobrepoe(nova, s) {
+				livre = false
+				break
+			}
+		}
+		if livre {
+			salas = append(salas, nova)
+		}
+	}
+
+	for _, s := range salas {
+		for y := s.y; y < s.y+s.a; y++ {
+			for x := s.x; x < s.x+s.l; x++ {
+				g[y][x] = Vazio.Simbolo
+			}
+		}
+	}
+
+	// liga cada sala à anterior, o que garante que tudo fica conectado
+	for i := 1; i < len(salas); i++ {
+		ax, ay := salas[i-1].x+salas[i-1].l/2, salas[i-1].y+salas[i-1].a/2
+		bx, by := salas[i].x+salas[i].l/2, salas[i].y+salas[i].a/2
+		if rng.Intn(2) == 0 {
+			cavarLinha(g, ax, ay, bx, ay)
+			cavarLinha(g, bx, ay, bx, by)
+		} else {
+			cavarLinha(g, ax, ay, ax, by)
+			cavarLinha(g, ax, by, bx, by)
+		}
+	}
+}
+
+// cava uma linha reta horizontal ou vertical entre dois pontos
+func cavarLinha(g gradeMapa, x0, y0, x1, y1 int) {
+	dx, dy := sinal(x1-x0), sinal(y1-y0)
+	for x, y := x0, y0; ; x, y = x+dx, y+dy {
+		if g.interna(x, y) {
+			g[y][x] = Vazio.Simbolo
+		}
+		if x == x1 && y == y1 {
+			return
+		}
+	}
+}
+
+// devolve -1, 0 ou 1 conforme o sinal de v
+func sinal(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// coloca saída, spawns, inimigos e vegetação sem quebrar a conectividade
+func povoarMapa(g gradeMapa, cfg ConfigGerador, rng *rand.Rand) error {
+	// a maior região livre vira o nível; o resto é fechado com parede
+	regiao := maiorRegiao(g)
+	if len(regiao) < cfg.Spawns+2 {
+		return fmt.Errorf("mapa gerado sem espaço livre suficiente")
+	}
+	naRegiao := make(map[Ponto]bool, len(regiao))
+	for _, p := range regiao {
+		naRegiao[p] = true
+	}
+	for y := range g {
+		for x := range g[y] {
+			if g[y][x] == Vazio.Simbolo && !naRegiao[Ponto{x, y}] {
+				g[y][x] = Parede.Simbolo
+			}
+		}
+	}
+
+	livres := func() []Ponto {
+		var pontos []Ponto
+		for _, p := range regiao {
+			if g[p.Y][p.X] == Vazio.Simbolo {
+				pontos = append(pontos, p)
+			}
+		}
+		return pontos
+	}
+
+	// spawns espalhados pela região
+	inicio := regiao[rng.Intn(len(regiao))]
+	g[inicio.Y][inicio.X] = SimboloSpawn
+	for i := 1; i < cfg.Spawns; i++ {
+		candidatos := livres()
+		p := candidatos[rng.Intn(len(candidatos))]
+		g[p.Y][p.X] = SimboloSpawn
+	}
+
+	// a saída fica no ponto livre mais distante do primeiro spawn
+	distancias := distanciasGrade(g, inicio)
+	saida, maior := Ponto{-1, -1}, -1
+	for _, p := range livres() {
+		if d := distancias[p]; d > maior {
+			saida, maior = p, d
+		}
+	}
+	if maior < 0 {
+		return fmt.Errorf("não foi possível posicionar a saída")
+	}
+	g[saida.Y][saida.X] = Saida.Simbolo
+
+	// inimigos são tangíveis: só ficam onde não isolam nenhuma parte do mapa
+	alcancaveis := len(distancias)
+	for i, tentativas := 0, 0; i < cfg.Inimigos && tentativas < cfg.Inimigos*20; tentativas++ {
+		candidatos := livres()
+		if len(candidatos) == 0 {
+			break
+		}
+		p := candidatos[rng.Intn(len(candidatos))]
+		g[p.Y][p.X] = Inimigo.Simbolo
+		if len(distanciasGrade(g, inicio)) != alcancaveis-1 {
+			g[p.Y][p.X] = Vazio.Simbolo
+			continue
+		}
+		alcancaveis--
+		i++
+	}
+
+	// vegetação não colide, então pode ir em qualquer lugar livre
+	for i := 0; i < cfg.Vegetacao; i++ {
+		candidatos := livres()
+		if len(candidatos) == 0 {
+			break
+		}
+		p := candidatos[rng.Intn(len(candidatos))]
+		g[p.Y][p.X] = Vegetacao.Simbolo
+	}
+
+	return nil
+}
+
+// verifica se a runa é atravessável durante a geração
+func atravessavel(r rune) bool {
+	return r != Parede.Simbolo && r != Inimigo.Simbolo
+}
+
+// BFS a partir de um ponto devolvendo a distância até cada célula alcançável
+func distanciasGrade(g gradeMapa, origem Ponto) map[Ponto]int {
+	dist := map[Ponto]int{origem: 0}
+	fila := []Ponto{origem}
+	for len(fila) > 0 {
+		p := fila[0]
+		fila = fila[1:]
+		for _, d := range []Ponto{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+			q := Ponto{p.X + d.X, p.Y + d.Y}
+			if _, visto := dist[q]; visto || !g.interna(q.X, q.Y) || !atravessavel(g[q.Y][q.X]) {
+				continue
+			}
+			dist[q] = dist[p] + 1
+			fila = append(fila, q)
+		}
+	}
+	return dist
+}
+
+// encontra a maior região conectada de células livres
+func maiorRegiao(g gradeMapa) []Ponto {
+	visitado := make(map[Ponto]bool)
+	var maior []Ponto
+	for y := range g {
+		for x := range g[y] {
+			p := Ponto{x, y}
+			if visitado[p] || !atravessavel(g[y][x]) || !g.interna(x, y) {
+				continue
+			}
+			var regiao []Ponto
+			for q := range distanciasGrade(g, p) {
+				visitado[q] = true
+				regiao = append(regiao, q)
+			}
+			if len(regiao) > len(maior) {
+				maior = regiao
+			}
+		}
+	}
+	// ordena pra que a mesma seed gere sempre o mesmo mapa (map não tem ordem)
+	ordenarPontos(maior)
+	return maior
+}
+
+// ordena pontos por linha e depois coluna
+func ordenarPontos(pontos []Ponto) {
+	slices.SortFunc(pontos, func(a, b Ponto) int {
+		if a.Y != b.Y {
+			return a.Y - b.Y
+		}
+		return a.X - b.X
+	})
+}
@@ -32,28 +32,107 @@ func LerEvento() EventoTeclado {
 		return EventoTeclado{Tipo: "interagir"}
 	}
 
+	// '/' abre a linha de comando (/stats, /top)
+	if ev.Ch == '/' {
+		return EventoTeclado{Tipo: "comando"}
+	}
+
+	// teclas da caminhada automática
+	switch ev.Ch {
+	case 'p':
+		return EventoTeclado{Tipo: "viajar_jogador"}
+	case 'x':
+		return EventoTeclado{Tipo: "viajar_saida"}
+	case 'm':
+		return EventoTeclado{Tipo: "marcar"}
+	case 'v':
+		return EventoTeclado{Tipo: "viajar_marca"}
+	}
+
 	// qualquer outra tecla é movimento
 	return EventoTeclado{Tipo: "mover", Tecla: ev.Ch}
 }
 
+// faz o LerEvento que está esperando uma tecla voltar na hora (com um evento vazio)
+func InterromperLeitura() {
+	termbox.Interrupt()
+}
+
+// lê uma linha digitada; mostrar é chamado a cada tecla pra redesenhar o texto.
+// Devolve ok=false se o jogador apertar ESC.
+func LerLinha(mostrar func(texto string)) (string, bool) {
+	var texto []rune
+	for {
+		mostrar(string(texto))
+		ev := termbox.PollEvent()
+		if ev.Type != termbox.EventKey {
+			continue
+		}
+		switch ev.Key {
+		case termbox.KeyEsc:
+			return "", false
+		case termbox.KeyEnter:
+			return string(texto), true
+		case termbox.KeyBackspace, termbox.KeyBackspace2:
+			if len(texto) > 0 {
+				texto = texto[:len(texto)-1]
+			}
+		case termbox.KeySpace:
+			texto = append(texto, ' ')
+		default:
+			if ev.Ch != 0 {
+				texto = append(texto, ev.Ch)
+			}
+		}
+	}
+}
+
+// painel de texto por cima de tudo (reconexão, estatísticas, ranking, linha de comando)
+func DesenharPainel(titulo string, linhas []string, rodape string) {
+	termbox.Clear(CorPadrao, CorPadrao)
+	for i, c := range []rune(titulo) {
+		termbox.SetCell(i+2, 2, c, CorAmarelo, CorPadrao)
+	}
+	for y, linha := range linhas {
+		for i, c := range []rune(linha) {
+			termbox.SetCell(i+2, 4+y, c, CorBranco, CorPadrao)
+		}
+	}
+	rodapeY := 4
+	if len(linhas) > 0 {
+		rodapeY += len(linhas) + 1
+	}
+	for i, c := range []rune(rodape) {
+		termbox.SetCell(i+2, rodapeY, c, CorTexto, CorPadrao)
+	}
+	termbox.Flush()
+}
+
 // desenha o estado do jogo (mapa + jogadores + status)
 func DesenharEstadoJogo(estado *EstadoJogo) {
 	termbox.Clear(CorPadrao, CorPadrao) // limpa a tela
 
+	// parte do mapa que aparece: tudo, ou uma janela em volta da câmera
+	origem, largura, altura := janelaMapa(estado)
+
 	// desenha o mapa
-	if estado.Mapa != nil {
-		for y, linha := range estado.Mapa {
-			for x, elem := range linha {
-				termbox.SetCell(x, y, elem.Simbolo, elem.Cor, elem.CorFundo)
-			}
+	for y := 0; y < altura; y++ {
+		linha := estado.Mapa[origem.Y+y]
+		for x := 0; x < largura && origem.X+x < len(linha); x++ {
+			elem := linha[origem.X+x]
+			termbox.SetCell(x, y, elem.Simbolo, elem.Cor, elem.CorFundo)
 		}
 	}
 
-	// desenha todos os jogadores conectados
+	// desenha todos os jogadores conectados (os que estão dentro da janela)
 	if estado.Jogadores != nil {
 		for _, jogador := range estado.Jogadores {
-			if jogador.Conectado {
-				termbox.SetCell(jogador.PosX, jogador.PosY, jogador.Simbolo, jogador.Cor, CorPadrao)
+			x, y := jogador.PosX-origem.X, jogador.PosY-origem.Y
+			if jogador.Conectado && x >= 0 && x < largura && y >= 0 && y < altura {
+				termbox.SetCell(x, y, jogador.Simbolo, jogador.Cor, CorPadrao)
+				if jogador.Emote != 0 && y > 0 {
+					termbox.SetCell(x, y-1, jogador.Emote, jogador.Cor|termbox.AttrBold, CorPadrao) // emote em cima do jogador
+				}
 			}
 		}
 	}
@@ -61,12 +140,19 @@ func DesenharEstadoJogo(estado *EstadoJogo) {
 	// mostra a mensagem de status
 	statusY := 0
 	if estado.Mapa != nil {
-		statusY = len(estado.Mapa) + 1
+		statusY = altura + 1
 	}
 	for i, c := range estado.StatusMsg {
 		termbox.SetCell(i, statusY, c, CorTexto, CorPadrao)
 	}
 
+	// aviso da administração do servidor, logo abaixo
+	if estado.Aviso != "" {
+		for i, c := range "[servidor] " + estado.Aviso {
+			termbox.SetCell(i, statusY+1, c, CorAmarelo, CorPadrao)
+		}
+	}
+
 	// lista de jogadores conectados
 	if estado.Jogadores != nil {
 		infoY := statusY + 2
@@ -92,10 +178,41 @@ func DesenharEstadoJogo(estado *EstadoJogo) {
 	if estado.Jogadores != nil {
 		instrY = statusY + 2 + len(estado.Jogadores) + 2
 	}
-	msg := "Use WASD para mover. ESC para sair."
+	msg := "Use WASD para mover. E ataca o inimigo do lado. P segue jogador, X vai à saída, M marca, V vai à marca. / para comandos. ESC para sair."
+	if estado.Instrucoes != "" {
+		msg = estado.Instrucoes
+	}
 	for i, c := range msg {
 		termbox.SetCell(i, instrY, c, CorTexto, CorPadrao)
 	}
 
+	// últimas mensagens do chat, embaixo de tudo
+	for j, linha := range estado.Chat {
+		for i, c := range linha {
+			termbox.SetCell(i, instrY+2+j, c, CorBranco, CorPadrao)
+		}
+	}
+
 	termbox.Flush() // atualiza a tela
 }
+
+// canto do mapa mostrado e o tamanho da janela: sem câmera é o mapa inteiro; com câmera
+// é o que cabe no terminal (deixando espaço pro texto embaixo) centrado nela
+func janelaMapa(estado *EstadoJogo) (origem Ponto, largura, altura int) {
+	altura = len(estado.Mapa)
+	for _, linha := range estado.Mapa {
+		largura = max(largura, len(linha))
+	}
+	if estado.Camera == nil {
+		return Ponto{}, largura, altura
+	}
+
+	telaLargura, telaAltura := termbox.Size()
+	texto := 6 + len(estado.Jogadores) + len(estado.Chat) // status, aviso, lista de jogadores, instruções e chat
+	visivelLargura := min(largura, max(telaLargura, 10))
+	visivelAltura := min(altura, max(telaAltura-texto, 5))
+
+	origem.X = max(0, min(estado.Camera.X-visivelLargura/2, largura-visivelLargura))
+	origem.Y = max(0, min(estado.Camera.Y-visivelAltura/2, altura-visivelAltura))
+	return origem, visivelLargura, visivelAltura
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Lotes: vários comandos numa RPC só, pra economizar idas e voltas em conexões
+// lentas. O servidor confere o lote inteiro antes de aceitar (ou entram todos ou
+// nenhum) e aplica os comandos em ordem, no mesmo tick, sem nada no meio. No
+// cliente, o -coalescer junta os movimentos feitos numa janela curta num lote.
+
+var (
+	ErrLoteGrande   = errors.New("lote com comandos demais")
+	ErrLoteRecusado = errors.New("lote recusado: outro comando do lote é inválido")
+	ErrOrdemLote    = errors.New("SequenceNumber fora de ordem no lote")
+	ErrSemLote      = errors.New("o servidor é de uma versão antiga, sem lotes")
+)
+
+// maior lote que o cliente junta sozinho (o limite do servidor é a rajada do
+// anti-cheat, que por padrão é isso; o que passar vai no lote seguinte)
+const comandosPorLote = 10
+
+// RPC: executa os comandos do lote em ordem e devolve um resultado pra cada um.
+// Como no Comando, só sessão inválida vira erro da RPC.
+func (gs *GameService) Lote(req LoteRequest, reply *LoteResponse) error {
+	if err := gs.verificarSessao(req.JogadorID, req.Token, "Lote"); err != nil {
+		return err
+	}
+	gs.servidor.registrarAtividade(req.JogadorID)
+
+	reply.Resultados = gs.executarLote(req)
+	reply.Posicoes = gs.servidor.posicoesPara(req.JogadorID)
+	return nil
+}
+
+// valida o lote inteiro, põe na fila como uma ação só e espera os resultados
+func (gs *GameService) executarLote(req LoteRequest) []ResultadoComando {
+	acoes := make([]acaoJogador, len(req.Comandos))
+	erros := make([]error, len(req.Comandos))
+	invalido := false
+	for i, comando := range req.Comandos {
+		acoes[i], erros[i] = gs.prepararAcao(ComandoRequest{
+			JogadorID:      req.JogadorID,
+			Token:          req.Token,
+			SequenceNumber: comando.SequenceNumber,
+			Comando:        comando.Comando,
+			Dados:          comando.Dados,
+		})
+		if erros[i] == nil && i > 0 && comando.SequenceNumber <= req.Comandos[i-1].SequenceNumber {
+			erros[i] = ErrOrdemLote
+		}
+		invalido = invalido || erros[i] != nil
+	}
+
+	// todos recebem o mesmo erro, menos os comandos que tinham o próprio
+	recusar := func(err error) []ResultadoComando {
+		resultados := make([]ResultadoComando, len(acoes))
+		for i, acao := range acoes {
+			resultados[i] = resultadoErro(acao, cmp.Or(erros[i], err))
+		}
+		return resultados
+	}
+
+	if len(acoes) == 0 {
+		return nil
+	}
+	if maximo := gs.servidor.config.RajadaMovimentos; len(acoes) > maximo {
+		return recusar(fmt.Errorf("%w: o máximo é %d", ErrLoteGrande, maximo))
+	}
+	if invalido {
+		return recusar(ErrLoteRecusado)
+	}
+	if err := gs.limitarAcao(req.JogadorID, acoes[len(acoes)-1].SequenceNumber, len(acoes)); err != nil {
+		return recusar(err)
+	}
+
+	// os já aplicados (o cliente repetiu o lote) respondem na hora; como a
+	// sequência cresce, são sempre os primeiros
+	resultados := make([]ResultadoComando, len(acoes))
+	processado := gs.servidor.snapshot.Load().processados[req.JogadorID]
+	novos := 0
+	for novos < len(acoes) && acoes[novos].SequenceNumber <= processado {
+		resultados[novos] = ResultadoComando{SequenceNumber: acoes[novos].SequenceNumber, Comando: acoes[novos].Comando, Duplicado: true}
+		novos++
+	}
+	if novos == len(acoes) {
+		return resultados
+	}
+
+	lote := acoes[novos:]
+	err := gs.enfileirar(acaoJogador{
+		JogadorID:      req.JogadorID,
+		SequenceNumber: lote[0].SequenceNumber,
+		Comando:        "lote",
+		lote:           lote,
+	})
+	for i, acao := range lote {
+		if err != nil {
+			resultados[novos+i] = resultadoErro(acao, err)
+			continue
+		}
+		resultados[novos+i] = gs.esperarResultado(acao)
+	}
+	return resultados
+}
+
+// ---- cliente ----
+
+// movimentos esperando a janela do -coalescer pra sair num lote só
+type loteCliente struct {
+	mutex     sync.Mutex    // protege pendentes
+	envio     sync.Mutex    // um envio por vez, pra sequência chegar em ordem no servidor
+	pendentes []ComandoLote // ainda sem SequenceNumber: o número sai na hora do envio
+	novo      chan struct{} // avisa o loop que chegou comando (espaço pra um aviso)
+	parar     chan struct{}
+	parado    chan struct{}
+}
+
+// liga a junção de movimentos se a config pedir e o servidor souber receber lotes
+func (gc *GameClient) iniciarLote() {
+	if gc.config.Coalescer <= 0 {
+		return
+	}
+	if gc.versaoServidor < VersaoLote {
+		log.Printf("O servidor fala a versão %d do protocolo, sem lotes: mandando um movimento por vez", gc.versaoServidor)
+		return
+	}
+	gc.lote = &loteCliente{
+		novo:   make(chan struct{}, 1),
+		parar:  make(chan struct{}),
+		parado: make(chan struct{}),
+	}
+	go gc.loopLote()
+}
+
+// para o loop de lotes mandando o que ainda estava esperando
+func (gc *GameClient) pararLote() {
+	if gc.lote == nil {
+		return
+	}
+	select {
+	case <-gc.lote.parar:
+	default:
+		close(gc.lote.parar)
+	}
+	<-gc.lote.parado
+}
+
+// guarda o comando pro próximo lote
+func (gc *GameClient) juntarNoLote(comando ComandoLote) {
+	gc.lote.mutex.Lock()
+	gc.lote.pendentes = append(gc.lote.pendentes, comando)
+	gc.lote.mutex.Unlock()
+
+	select {
+	case gc.lote.novo <- struct{}{}:
+	default: // o loop já foi avisado
+	}
+}
+
+// espera o primeiro comando, deixa a janela passar e manda tudo que juntou
+func (gc *GameClient) loopLote() {
+	defer close(gc.lote.parado)
+
+	for {
+		select {
+		case <-gc.lote.novo:
+		case <-gc.lote.parar:
+			gc.descarregarLote()
+			return
+		}
+
+		select {
+		case <-time.After(gc.config.Coalescer):
+		case <-gc.lote.parar:
+			gc.descarregarLote()
+			return
+		}
+		gc.descarregarLote()
+	}
+}
+
+// manda agora os comandos que estão esperando
+func (gc *GameClient) descarregarLote() {
+	gc.lote.envio.Lock()
+	defer gc.lote.envio.Unlock()
+	gc.enviarPendentes()
+}
+
+// manda os pendentes em lotes (quem chama segura lote.envio). Erro de um
+// movimento não precisa de aviso: as posições que voltam corrigem a previsão.
+func (gc *GameClient) enviarPendentes() error {
+	gc.lote.mutex.Lock()
+	pendentes := gc.lote.pendentes
+	gc.lote.pendentes = nil
+	gc.lote.mutex.Unlock()
+
+	for parte := range slices.Chunk(pendentes, comandosPorLote) {
+		if _, err := gc.enviarLote(parte); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Manda os comandos num lote só e devolve um resultado pra cada um, na mesma
+// ordem. Os SequenceNumber são preenchidos aqui, na sequência do cliente.
+func (gc *GameClient) Lote(comandos []ComandoLote) ([]ResultadoComando, error) {
+	if gc.lote != nil {
+		// os movimentos juntados vieram antes: vão primeiro
+		gc.lote.envio.Lock()
+		defer gc.lote.envio.Unlock()
+		if err := gc.enviarPendentes(); err != nil {
+			return nil, err
+		}
+	}
+	return gc.enviarLote(comandos)
+}
+
+func (gc *GameClient) enviarLote(comandos []ComandoLote) ([]ResultadoComando, error) {
+	if gc.versaoServidor < VersaoLote {
+		return nil, ErrSemLote
+	}
+
+	sessao := gc.sessao()
+	req := LoteRequest{JogadorID: sessao.JogadorID, Token: sessao.Token, Comandos: slices.Clone(comandos)}
+	for i := range req.Comandos {
+		req.Comandos[i].SequenceNumber = atomic.AddInt64(&gc.sequenceNumber, 1)
+	}
+
+	var resp LoteResponse
+	if err := gc.client.Call("GameService.Lote", req, &resp); err != nil {
+		gc.verificarQueda(err)
+		return nil, err
+	}
+	gc.aplicarPosicoes(resp.Posicoes)
+	gc.notificar()
+	return resp.Resultados, nil
+}
+
+// movimento no formato de comando de lote
+func comandoLoteMover(tecla rune) ComandoLote {
+	dados, _ := json.Marshal(DadosMover{Tecla: tecla}) // não tem como falhar
+	return ComandoLote{Comando: ComandoMover, Dados: dados}
+}
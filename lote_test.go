@@ -0,0 +1,152 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// movimento de lote com o número de sequência já preenchido
+func moverNoLote(seq int64, tecla rune) ComandoLote {
+	comando := comandoLoteMover(tecla)
+	comando.SequenceNumber = seq
+	return comando
+}
+
+func TestLote(t *testing.T) {
+	// o jogador nasce em (1, 1): pra baixo é parede, pra direita e depois pra baixo não
+	mapa := []string{
+		"▤▤▤▤▤",
+		"▤☺ ▤▤",
+		"▤▤  ▤",
+		"▤▤▤▤▤",
+	}
+
+	casos := []struct {
+		nome       string
+		antes      []ComandoLote // lote mandado antes (e aplicado)
+		fichas     float64       // fichas do anti-cheat antes do lote (0 = as da rajada)
+		comandos   []ComandoLote
+		resultados []string // "ok" = aplicado, "dup" = duplicado, ou um pedaço do erro
+		posicao    Ponto
+	}{
+		{
+			nome:       "aplica em ordem",
+			comandos:   []ComandoLote{moverNoLote(1, 'd'), moverNoLote(2, 's')},
+			resultados: []string{"ok", "ok"},
+			posicao:    Ponto{2, 2},
+		},
+		{
+			nome:       "a ordem importa",
+			comandos:   []ComandoLote{moverNoLote(1, 's'), moverNoLote(2, 'd')},
+			resultados: []string{"ok", "ok"}, // o 's' bate na parede, mas foi aplicado
+			posicao:    Ponto{2, 1},
+		},
+		{
+			nome:       "vazio",
+			posicao:    Ponto{1, 1},
+			resultados: []string{},
+		},
+		{
+			nome:       "comando inválido recusa o lote inteiro",
+			comandos:   []ComandoLote{moverNoLote(1, 'd'), {SequenceNumber: 2, Comando: "voar"}, moverNoLote(3, 's')},
+			resultados: []string{ErrLoteRecusado.Error(), ErrComandoDesconhecido.Error(), ErrLoteRecusado.Error()},
+			posicao:    Ponto{1, 1},
+		},
+		{
+			nome:       "sequência fora de ordem",
+			comandos:   []ComandoLote{moverNoLote(2, 'd'), moverNoLote(1, 's')},
+			resultados: []string{ErrLoteRecusado.Error(), ErrOrdemLote.Error()},
+			posicao:    Ponto{1, 1},
+		},
+		{
+			nome: "maior que a rajada",
+			comandos: []ComandoLote{
+				moverNoLote(1, 'd'), moverNoLote(2, 'a'), moverNoLote(3, 'd'), moverNoLote(4, 'a'),
+				moverNoLote(5, 'd'), moverNoLote(6, 'a'), moverNoLote(7, 'd'), moverNoLote(8, 'a'),
+				moverNoLote(9, 'd'), moverNoLote(10, 'a'), moverNoLote(11, 'd'),
+			},
+			resultados: slices.Repeat([]string{ErrLoteGrande.Error()}, 11),
+			posicao:    Ponto{1, 1},
+		},
+		{
+			nome:       "sem fichas pro lote inteiro",
+			fichas:     1,
+			comandos:   []ComandoLote{moverNoLote(1, 'd'), moverNoLote(2, 's')},
+			resultados: []string{ErrLimiteMovimento.Error(), ErrLimiteMovimento.Error()},
+			posicao:    Ponto{1, 1},
+		},
+		{
+			nome:       "lote repetido",
+			antes:      []ComandoLote{moverNoLote(1, 'd'), moverNoLote(2, 's')},
+			comandos:   []ComandoLote{moverNoLote(1, 'd'), moverNoLote(2, 's')},
+			resultados: []string{"dup", "dup"},
+			posicao:    Ponto{2, 2},
+		},
+		{
+			nome:       "lote repetido com um comando novo no fim",
+			antes:      []ComandoLote{moverNoLote(1, 'd')},
+			comandos:   []ComandoLote{moverNoLote(1, 'd'), moverNoLote(2, 's'), moverNoLote(3, 'd')},
+			resultados: []string{"dup", "ok", "ok"},
+			posicao:    Ponto{3, 2},
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			cfg := ConfigServidorPadrao
+			cfg.Mapa = mapa
+			gs := servidorDeTeste(t, cfg)
+			servico := servicoDeTeste(t, gs)
+			sessao := entrarDeTeste(t, servico, "Ana")
+
+			lote := func(comandos []ComandoLote) LoteResponse {
+				t.Helper()
+				var resp LoteResponse
+				req := LoteRequest{JogadorID: sessao.JogadorID, Token: sessao.Token, Comandos: comandos}
+				if err := servico.Lote(req, &resp); err != nil {
+					t.Fatalf("erro no Lote: %v", err)
+				}
+				return resp
+			}
+
+			if c.antes != nil {
+				lote(c.antes)
+			}
+			ctrl, _ := servico.conexao.controle(sessao.JogadorID)
+			ctrl.fichas = float64(cfg.RajadaMovimentos)
+			if c.fichas > 0 {
+				ctrl.fichas = c.fichas
+			}
+
+			resp := lote(c.comandos)
+			if len(resp.Resultados) != len(c.resultados) {
+				t.Fatalf("%d resultados, esperava %d: %+v", len(resp.Resultados), len(c.resultados), resp.Resultados)
+			}
+			for i, r := range resp.Resultados {
+				if r.SequenceNumber != c.comandos[i].SequenceNumber {
+					t.Errorf("resultado %d é do SequenceNumber %d, esperava %d", i, r.SequenceNumber, c.comandos[i].SequenceNumber)
+				}
+				switch esperado := c.resultados[i]; esperado {
+				case "ok":
+					if !r.Aplicado || r.Erro != "" {
+						t.Errorf("resultado %d: %+v, esperava aplicado", i, r)
+					}
+				case "dup":
+					if !r.Duplicado {
+						t.Errorf("resultado %d: %+v, esperava duplicado", i, r)
+					}
+				default:
+					if r.Aplicado || !strings.Contains(r.Erro, esperado) {
+						t.Errorf("resultado %d: %+v, esperava o erro %q", i, r, esperado)
+					}
+				}
+			}
+
+			jogador := resp.Posicoes.Jogadores[sessao.JogadorID]
+			if p := (Ponto{jogador.PosX, jogador.PosY}); p != c.posicao {
+				t.Errorf("o jogador terminou em %v, esperava %v", p, c.posicao)
+			}
+		})
+	}
+}
@@ -1,62 +1,422 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
 	// Verifica se foi passado o argumento "--server"
 	servidor := flag.Bool("server", false, "Servidor")
+	gerar := flag.Bool("generate", false, "Gera um mapa procedural e salva/imprime no formato texto")
+	mapaServidor := flag.String("mapa", "", "Arquivo de mapa que o servidor envia aos clientes")
+	tipoMapa := flag.String("tipo", "", "Tipo de mapa gerado: labirinto ou masmorra (no servidor, gera o mapa do jogo)")
+	cfgGerador := ConfigGeradorPadrao
+	flag.IntVar(&cfgGerador.Largura, "largura", cfgGerador.Largura, "Largura do mapa gerado")
+	flag.IntVar(&cfgGerador.Altura, "altura", cfgGerador.Altura, "Altura do mapa gerado")
+	flag.Int64Var(&cfgGerador.Seed, "seed", cfgGerador.Seed, "Semente do gerador de mapas")
+	flag.IntVar(&cfgGerador.Spawns, "spawns", cfgGerador.Spawns, "Quantidade de pontos de nascimento no mapa gerado")
+	flag.IntVar(&cfgGerador.Inimigos, "inimigos", cfgGerador.Inimigos, "Quantidade de inimigos no mapa gerado")
+	flag.IntVar(&cfgGerador.Vegetacao, "vegetacao", cfgGerador.Vegetacao, "Quantidade de vegetação no mapa gerado")
+	saida := flag.String("saida", "", "Arquivo onde o mapa gerado é salvo (padrão: imprime na tela)")
+	editar := flag.String("edit", "", "Abre o editor de mapas no arquivo informado")
+	bots := flag.Int("bot", 0, "Conecta N jogadores controlados pelo computador (sem interface)")
+	cfgServidor := ConfigServidorPadrao
+	flag.IntVar(&cfgServidor.TaxaTick, "tick", cfgServidor.TaxaTick, "Ticks por segundo da simulação do servidor")
+	flag.IntVar(&cfgServidor.MovimentosPorTick, "movtick", cfgServidor.MovimentosPorTick, "Movimentos de cada jogador aplicados por tick")
+	flag.DurationVar(&cfgServidor.TempoInatividade, "inatividade", cfgServidor.TempoInatividade, "Remove jogadores sem atividade depois desse tempo (0 = nunca)")
+	flag.IntVar(&cfgServidor.MovimentosPorSegundo, "maxmov", cfgServidor.MovimentosPorSegundo, "Movimentos por segundo aceitos de cada jogador")
+	flag.BoolVar(&cfgServidor.ExpulsarInfratores, "kick", cfgServidor.ExpulsarInfratores, "Expulsa jogadores que acumulam infrações do anti-cheat")
+	flag.IntVar(&cfgServidor.LimiteInfracoes, "infracoes", cfgServidor.LimiteInfracoes, "Infrações até a expulsão (com -kick)")
+	flag.DurationVar(&cfgServidor.AvisoDesligamento, "aviso-desligamento", cfgServidor.AvisoDesligamento, "Contagem regressiva mostrada aos jogadores quando o servidor desliga")
+	flag.StringVar(&cfgServidor.ArquivoEstado, "estado", "", "Servidor: arquivo JSON onde o mundo é salvo e restaurado ao reiniciar")
+	flag.DurationVar(&cfgServidor.IntervaloSalvamento, "salvar-cada", cfgServidor.IntervaloSalvamento, "Servidor: intervalo entre salvamentos do estado")
+	flag.StringVar(&cfgServidor.ArquivoGravacao, "gravar", "", "Servidor: grava a partida nesse arquivo pra ver depois com -replay")
+	replay := flag.String("replay", "", "Reproduz uma partida gravada com -gravar")
+	flag.StringVar(&cfgServidor.Senha, "senha-servidor", "", "Servidor: senha exigida pra entrar no jogo")
+	flag.StringVar(&cfgServidor.SenhaAdmin, "senha-admin", "", "Senha que libera a administração remota (no servidor e no -admin)")
+	admin := flag.Bool("admin", false, "Abre o console de administração de um servidor remoto (com -senha-admin)")
+	senha := flag.String("senha", "", "Cliente: senha do servidor")
+	nome := flag.String("nome", "", "Cliente: nome do jogador")
+	segredo := flag.String("segredo", "", "Cliente: segredo do perfil (guarda as estatísticas do -nome no servidor)")
+	espectador := flag.Bool("espectador", false, "Cliente: entra só pra assistir (A/D troca o jogador seguido, M mostra o mapa inteiro)")
+	var rede RedeSimulada
+	flag.DurationVar(&rede.Latencia, "latencia", 0, "Desenvolvimento: atrasa cada sentido das conexões RPC (cliente, bots, teste de carga ou servidor)")
+	flag.DurationVar(&rede.Variacao, "variacao", 0, "Desenvolvimento: sorteia até isso a mais ou a menos na -latencia")
+	flag.IntVar(&rede.Banda, "banda", 0, "Desenvolvimento: limita cada sentido das conexões a tantos bytes por segundo")
+	flag.DurationVar(&rede.QuedaApos, "queda-apos", 0, "Desenvolvimento: derruba cada conexão depois desse tempo")
+	flag.Float64Var(&rede.ChanceQueda, "chance-queda", 0, "Desenvolvimento: chance (0 a 1) de derrubar a conexão a cada escrita")
+	flag.Int64Var(&rede.Semente, "semente-rede", 1, "Desenvolvimento: semente da rede simulada (a mesma semente repete os mesmos atrasos e quedas)")
+	coalescer := flag.Duration("coalescer", 0, "Cliente e bots: junta os movimentos feitos nessa janela numa RPC só (ex: 50ms; 0 = manda cada um na hora)")
+	flag.StringVar(&cfgServidor.ArquivoPerfis, "perfis", "", "Servidor: arquivo JSON com os perfis e estatísticas dos jogadores")
+	host := flag.String("host", LocalConfig.Host, "Endereço do servidor (cliente, bots e teste de carga)")
+	porta := flag.String("porta", LocalConfig.Port, "Porta do servidor")
+	portaJSON := flag.String("porta-json", "", "Servidor: porta do gateway JSON-RPC (vazio = desligado)")
+	portaWeb := flag.String("porta-web", "", "Servidor: porta HTTP do gateway WebSocket em /ws (vazio = desligado)")
+	flag.BoolVar(&cfgServidor.PaginaWeb, "pagina", cfgServidor.PaginaWeb, "Servidor: serve o cliente do navegador em / na -porta-web")
+	usarTLS := flag.Bool("tls", false, "Cliente: conecta ao servidor usando TLS")
+	certFile := flag.String("cert", "", "Servidor: certificado TLS em PEM (liga o TLS junto com -key)")
+	keyFile := flag.String("key", "", "Servidor: chave privada TLS em PEM")
+	caFile := flag.String("ca", "", "Cliente: certificado da CA usada pra verificar o servidor")
+	fingerprint := flag.String("fingerprint", "", "Cliente: SHA-256 esperado do certificado do servidor (certificados autoassinados)")
+	gerarCert := flag.Bool("gerar-cert", false, "Gera um certificado autoassinado (cert.pem/key.pem ou -cert/-key) pros hosts em -host")
+	testeCarga := flag.Bool("loadtest", false, "Roda um teste de carga contra o servidor")
+	cfgCarga := ConfigCarga{Conexoes: 200, Duracao: 30 * time.Second, Cenario: CenarioMisto}
+	flag.IntVar(&cfgCarga.Conexoes, "conexoes", cfgCarga.Conexoes, "Conexões simultâneas do teste de carga")
+	flag.DurationVar(&cfgCarga.Duracao, "duracao", cfgCarga.Duracao, "Duração do teste de carga")
+	flag.StringVar(&cfgCarga.Cenario, "cenario", cfgCarga.Cenario, "Cenário do teste de carga: conectar, mover, consultar ou misto")
+	flag.DurationVar(&cfgCarga.Intervalo, "intervalo", cfgCarga.Intervalo, "Pausa entre chamadas de cada conexão no teste de carga")
 	flag.Parse() // processa os argumentos da linha de comando
 
-	if *servidor {
-		runServidor() // se for servidor, roda o servidor
-	} else {
-		runCliente() // se não, roda o cliente
+	if *tipoMapa != "" {
+		cfgGerador.Tipo = *tipoMapa
 	}
+	config := NewConfig(*host, *porta, LocalConfig.DefaultMapFile)
+	config.TLS = *usarTLS || *caFile != "" || *fingerprint != ""
+	config.CertFile, config.KeyFile = *certFile, *keyFile
+	config.CAFile, config.Fingerprint = *caFile, *fingerprint
+	config.Senha = *senha
+	config.PortaJSON, config.PortaWeb = *portaJSON, *portaWeb
+	config.Nome, config.Segredo = *nome, *segredo
+	config.Espectador = *espectador
+	config.Coalescer = *coalescer
+	config.Rede = rede
+	if rede.Ativa() {
+		log.Printf("Rede simulada ligada: %s", rede)
+	}
+
+	switch {
+	case *gerarCert:
+		runGerarCertificado(config, *host)
+	case *gerar:
+		runGerador(cfgGerador, *saida) // só gera o mapa e sai
+	case *bots > 0:
+		runBots(*bots, config, config.DefaultMapFile)
+	case *admin:
+		runAdmin(config, cfgServidor.SenhaAdmin)
+	case *replay != "":
+		runReplay(*replay, config.DefaultMapFile)
+	case *testeCarga:
+		runTesteCarga(config, cfgCarga)
+	case *editar != "":
+		if err := runEditor(*editar, cfgGerador.Largura, cfgGerador.Altura); err != nil {
+			log.Fatal("Erro no editor:", err)
+		}
+	case *servidor:
+		runServidor(config, cfgServidor, *mapaServidor, *tipoMapa != "", cfgGerador) // se for servidor, roda o servidor
+	default:
+		runCliente(config) // se não, roda o cliente
+	}
+}
+
+// Gera um mapa e salva no arquivo (ou imprime se nenhum arquivo foi dado)
+func runGerador(cfg ConfigGerador, arquivo string) {
+	linhas, err := GerarMapa(cfg)
+	if err != nil {
+		log.Fatal("Erro ao gerar mapa:", err)
+	}
+
+	if arquivo == "" {
+		fmt.Println(strings.Join(linhas, "\n"))
+		return
+	}
+	if err := SalvarMapa(arquivo, linhas); err != nil {
+		log.Fatal("Erro ao salvar mapa:", err)
+	}
+	log.Printf("Mapa %s %dx%d (seed %d) salvo em %s", cfg.Tipo, cfg.Largura, cfg.Altura, cfg.Seed, arquivo)
+}
+
+// Mostra um erro claro pro jogador e encerra (sem o stack do log.Fatal)
+func sairComErro(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(1)
+}
+
+// Pede uma senha no terminal
+func perguntarSenha(pergunta string) string {
+	fmt.Print(pergunta)
+	linha, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(linha)
+}
+
+// Gera um certificado autoassinado pra jogos locais com TLS
+func runGerarCertificado(config NetworkConfig, host string) {
+	certFile, keyFile := config.CertFile, config.KeyFile
+	if certFile == "" {
+		certFile = "cert.pem"
+	}
+	if keyFile == "" {
+		keyFile = "key.pem"
+	}
+
+	// vale pro host pedido (ex: o ip da máquina na rede) e pra máquina local
+	hosts := []string{host}
+	for _, h := range []string{"localhost", "127.0.0.1"} {
+		if h != host {
+			hosts = append(hosts, h)
+		}
+	}
+
+	fp, err := GerarCertificado(certFile, keyFile, hosts, 365*24*time.Hour)
+	if err != nil {
+		log.Fatal("Erro ao gerar certificado:", err)
+	}
+	fmt.Printf("Certificado salvo em %s e chave em %s (hosts: %s)\n", certFile, keyFile, strings.Join(hosts, ", "))
+	fmt.Printf("Servidor: go run . -server -cert %s -key %s\n", certFile, keyFile)
+	fmt.Printf("Cliente:  go run . -host %s -fingerprint %s\n", host, fp)
 }
 
 // Iniciar o servidor de posições dos jogadores
-func runServidor() {
-	server := NewGameServer()
-	log.Println("Servidor de posições iniciado na porta 8080")
-	log.Fatal(server.StartRPC("8080")) // inicia o servidor e encerra se der erro
+func runServidor(config NetworkConfig, cfg ConfigServidor, mapaFile string, gerarMapa bool, cfgGerador ConfigGerador) {
+	// Se pediu um mapa (gerado ou de arquivo), o servidor passa a distribuí-lo
+	var err error
+	switch {
+	case gerarMapa:
+		cfg.Mapa, err = GerarMapa(cfgGerador)
+		log.Printf("Mapa %s gerado com seed %d", cfgGerador.Tipo, cfgGerador.Seed)
+	case mapaFile != "":
+		cfg.Mapa, err = LerLinhasMapa(mapaFile)
+	}
+	if err != nil {
+		log.Fatal("Erro ao preparar mapa:", err)
+	}
+
+	server, err := NewGameServerWithConfig(cfg)
+	if err != nil {
+		log.Fatal("Erro ao criar servidor:", err)
+	}
+
+	// Console de administração no próprio terminal do servidor
+	go func() {
+		fmt.Println("Console de administração: digite ajuda para ver os comandos")
+		RodarConsoleAdmin(server, os.Stdin, os.Stdout)
+	}()
+
+	log.Println("Servidor de posições iniciado na porta", config.Port)
+	erroRede := make(chan error, 3)
+	go func() { erroRede <- server.StartRPCWithConfig(config) }()
+
+	// Gateway pra clientes em outras linguagens, nas portas próprias (com o mesmo TLS)
+	if config.PortaJSON != "" {
+		configJSON := config
+		configJSON.Port = config.PortaJSON
+		go func() { erroRede <- server.StartJSONRPC(configJSON) }()
+	}
+	if config.PortaWeb != "" {
+		configWeb := config
+		configWeb.Port = config.PortaWeb
+		go func() { erroRede <- server.StartWeb(configWeb) }()
+	}
+
+	// Ctrl+C (ou SIGTERM) desliga avisando os jogadores; um segundo Ctrl+C força a saída
+	sinais := make(chan os.Signal, 1)
+	signal.Notify(sinais, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sinais
+		go server.Desligar("servidor encerrado", cfg.AvisoDesligamento)
+		<-sinais
+		log.Fatal("Saindo sem esperar o desligamento")
+	}()
+
+	select {
+	case err := <-erroRede:
+		if !errors.Is(err, ErrServidorDesligado) {
+			log.Fatal(err) // não conseguiu escutar na porta
+		}
+	case <-server.Desligado(): // desligado pelo console ou pela administração remota
+	}
+	<-server.Desligado()
 }
 
-// Iniciar cliente
-func runCliente() {
-	IniciarInterface()
-	defer FinalizarInterface()
+// Console de administração conectado a um servidor remoto
+func runAdmin(config NetworkConfig, senha string) {
+	if senha == "" {
+		senha = perguntarSenha("Senha de administrador: ")
+	}
+	adm, err := ConectarAdmin(config, senha)
+	if err != nil {
+		sairComErro(MensagemErro(err, config))
+	}
+	defer adm.Close()
+
+	fmt.Printf("Administrando %s: digite ajuda para ver os comandos, sair para sair\n", config.GetAddress())
+	RodarConsoleAdmin(adm, os.Stdin, os.Stdout)
+}
 
+// Iniciar cliente
+func runCliente(config NetworkConfig) {
 	log.Println("Iniciando cliente...")
-	client, err := NewGameClient() // tenta criar um novo cliente
+	client, err := conectarCliente(&config, true)
+	if err != nil {
+		sairComErro(MensagemErro(err, config)) // se não conseguir conectar, mostra o motivo e sai
+	}
+
+	// Só abre a interface depois de conectar, assim os erros aparecem no terminal normal
+	tela, err := NovoRenderizadorTermbox()
+	if err != nil {
+		client.Close()
+		log.Fatal("Erro ao iniciar a interface:", err)
+	}
+	defer tela.Fechar()
+
+	for {
+		motivo := jogar(client, tela)
+		client.Close()
+		if motivo == "" {
+			return // saiu com ESC
+		}
+
+		// Tela de reconexão até conseguir voltar ou o jogador desistir
+		for client = nil; client == nil; {
+			tela.DesenharLobby(motivo, "R para reconectar, ESC para sair")
+			evento := LerEvento()
+			if evento.Tipo == "sair" {
+				return
+			}
+			if evento.Tecla != 'r' && evento.Tecla != 'R' {
+				continue
+			}
+			tela.DesenharLobby("Reconectando em "+config.GetAddress()+"...", "")
+			if client, err = conectarCliente(&config, false); err != nil {
+				motivo = MensagemErro(err, config)
+			}
+		}
+	}
+}
+
+// Cria o cliente e entra no jogo; com perguntar, pede a senha no terminal se o servidor exigir
+func conectarCliente(config *NetworkConfig, perguntar bool) (*GameClient, error) {
+	client, err := NewGameClientWithConfig(*config) // tenta criar um novo cliente
 	if err != nil {
-		log.Fatal("Erro ao conectar:", err)
+		return nil, err
 	}
-	defer client.Close()
 
 	// Conecta ao servidor e carrega o jogo local
 	log.Println("Conectando ao jogo...")
-	jogadorID, err := client.ConectarJogo("mapa.txt")
+	jogadorID, err := client.ConectarJogo(config.DefaultMapFile)
+	if err != nil && err.Error() == ErrSenhaIncorreta.Error() && perguntar && config.Senha == "" {
+		// servidor com senha e o jogador não passou nenhuma: pergunta uma vez
+		config.Senha = perguntarSenha("Este servidor pede senha: ")
+		client.config.Senha = config.Senha
+		jogadorID, err = client.ConectarJogo(config.DefaultMapFile)
+	}
 	if err != nil {
-		log.Fatal("Erro ao conectar ao jogo:", err) // se não conseguir conectar, finaliza
+		client.Close()
+		return nil, err
 	}
 	log.Println("Conectado com sucesso! ID:", jogadorID)
+	return client, nil
+}
+
+// Joga até o jogador sair (devolve "") ou a conexão cair (devolve o motivo)
+func jogar(client *GameClient, tela *RenderizadorTermbox) string {
+	jogadorID := client.jogadorID
+	client.DefinirRenderizador(tela)
 
 	// Começa a sincronizar estado com o servidor
 	client.IniciarSincronizacao(jogadorID)
 	defer client.PararSincronizacao()
 
+	// Se a conexão cair, acorda o loop que está esperando uma tecla
+	fim := make(chan struct{})
+	defer close(fim)
+	go func() {
+		select {
+		case <-client.Caiu():
+			InterromperLeitura()
+		case <-fim:
+		}
+	}()
+
 	// Loop principal do jogo
 	for {
 		evento := LerEvento() // lê o que o jogador apertou
 
+		select {
+		case <-client.Caiu():
+			return client.MotivoQueda()
+		default:
+		}
+
 		if evento.Tipo == "sair" {
-			break // se apertou esc, sai do jogo
+			return "" // se apertou esc, sai do jogo
+		}
+		if evento.Tipo == "comando" {
+			executarComandoCliente(client, tela)
+			continue
+		}
+		if client.Espectador() {
+			client.ProcessarEventoEspectador(evento) // espectador só mexe a câmera
+			continue
 		}
 		if evento.Tipo == "mover" {
+			client.CancelarViagem()               // andar na mão interrompe a caminhada automática
 			client.Mover(jogadorID, evento.Tecla) // envia o movimento pro servidor
 		}
+		if evento.Tipo == "interagir" {
+			if alvo, err := client.Interagir(); err != nil {
+				client.gameManager.DefinirStatus(MensagemErro(err, client.config))
+			} else {
+				client.gameManager.DefinirStatus(fmt.Sprintf("Você derrotou o inimigo em (%d, %d)!", alvo.X, alvo.Y))
+			}
+			client.notificar()
+		}
+		client.ProcessarEventoViagem(evento) // teclas de viagem (P, X, M, V)
+	}
+}
+
+// Lê e executa um comando digitado depois da '/'
+func executarComandoCliente(client *GameClient, tela *RenderizadorTermbox) {
+	defer tela.FecharPainel()
+
+	linha, ok := LerLinha(func(texto string) {
+		tela.MostrarPainel("Comando", []string{"/" + texto}, "/stats  suas estatísticas    /top [corridas|distancia|tempo|partidas|inimigos]  ranking    /chat <texto>    /emote <nome>    ESC cancela")
+	})
+	if !ok {
+		return
+	}
+
+	comando, argumento, _ := strings.Cut(strings.TrimSpace(linha), " ")
+	var titulo string
+	var linhas []string
+	switch comando {
+	case "stats":
+		estatisticas, err := client.ObterEstatisticas()
+		if err != nil {
+			titulo, linhas = "Estatísticas", []string{MensagemErro(err, client.config)}
+			break
+		}
+		titulo, linhas = "Estatísticas de "+estatisticas.Nome, LinhasEstatisticas(estatisticas)
+	case "top":
+		ranking, err := client.Ranking(strings.TrimSpace(argumento), 10)
+		if err != nil {
+			titulo, linhas = "Ranking", []string{MensagemErro(err, client.config)}
+			break
+		}
+		titulo, linhas = "Ranking por "+ranking.Criterio, LinhasRanking(ranking)
+	case "chat", "emote":
+		var err error
+		if comando == "chat" {
+			err = client.Chat(argumento)
+		} else {
+			err = client.Emote(strings.TrimSpace(argumento))
+		}
+		if err == nil {
+			return // a mensagem (ou o emote) aparece na tela do jogo
+		}
+		titulo, linhas = strings.ToUpper(comando[:1])+comando[1:], []string{MensagemErro(err, client.config)}
+	default:
+		titulo, linhas = "Comando desconhecido", []string{"/" + linha}
 	}
+
+	tela.MostrarPainel(titulo, linhas, "Aperte qualquer tecla para voltar")
+	LerEvento()
 }
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// erros dos perfis
+var (
+	ErrPerfisDesativados = errors.New("este servidor não guarda perfis")
+	ErrSegredoIncorreto  = errors.New("segredo do perfil incorreto")
+	ErrPerfilEmUso       = errors.New("esse perfil já está jogando")
+	ErrSemPerfil         = errors.New("você está jogando sem perfil (use -nome e -segredo)")
+)
+
+// custo do hash do segredo: alto o bastante pra atrapalhar força bruta num arquivo vazado
+const iteracoesSegredo = 100_000
+
+// critérios do ranking
+const (
+	RankingCorridas  = "corridas"
+	RankingDistancia = "distancia"
+	RankingTempo     = "tempo"
+	RankingPartidas  = "partidas"
+	RankingInimigos  = "inimigos"
+)
+
+// estatísticas públicas de um jogador (o que vai pela rede)
+type EstatisticasJogador struct {
+	Nome               string        `json:"nome"`
+	TempoJogo          time.Duration `json:"tempo_jogo_ns"`
+	Distancia          int64         `json:"distancia"`           // passos dados
+	Partidas           int           `json:"partidas"`            // vezes que entrou no jogo
+	CorridasVencidas   int           `json:"corridas_vencidas"`   // vezes que chegou à saída (⚑)
+	InimigosDerrotados int           `json:"inimigos_derrotados"` // inimigos derrotados
+}
+
+// perfil guardado em disco: as estatísticas mais o hash do segredo
+type Perfil struct {
+	EstatisticasJogador
+	Sal      string    `json:"sal"`
+	Hash     string    `json:"hash"`
+	CriadoEm time.Time `json:"criado_em"`
+}
+
+type RankingRequest struct {
+	Criterio string // um dos Ranking* (vazio = corridas)
+	Limite   int    // quantos perfis devolver (0 = 10)
+}
+
+type RankingResponse struct {
+	Criterio string
+	Perfis   []EstatisticasJogador
+}
+
+// ArmazemPerfis guarda os perfis num arquivo JSON, pelo nome
+type ArmazemPerfis struct {
+	arquivo    string
+	mutex      sync.Mutex // protege perfis, alteracoes e salvas
+	gravacao   sync.Mutex // um Salvar por vez, pra um arquivo velho não passar por cima de um novo
+	perfis     map[string]*Perfil
+	alteracoes int64 // quantas mudanças desde que o armazém foi aberto
+	salvas     int64 // quantas dessas mudanças já estão no arquivo
+}
+
+// Abre o armazém; arquivo que ainda não existe começa vazio
+func AbrirArmazemPerfis(arquivo string) (*ArmazemPerfis, error) {
+	a := &ArmazemPerfis{arquivo: arquivo, perfis: make(map[string]*Perfil)}
+
+	dados, err := os.ReadFile(arquivo)
+	if errors.Is(err, fs.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(dados, &a.perfis); err != nil {
+		return nil, fmt.Errorf("arquivo de perfis %s inválido: %w", arquivo, err)
+	}
+	return a, nil
+}
+
+// hash do segredo com o sal do perfil
+func hashSegredo(segredo, sal string) string {
+	chave, _ := pbkdf2.Key(sha256.New, segredo, []byte(sal), iteracoesSegredo, 32)
+	return hex.EncodeToString(chave)
+}
+
+// Entra no perfil do nome, criando se ainda não existir
+func (a *ArmazemPerfis) Entrar(nome, segredo string) (criado bool, err error) {
+	a.mutex.Lock()
+	perfil, existe := a.perfis[nome]
+	var sal, esperado string
+	if existe {
+		sal, esperado = perfil.Sal, perfil.Hash
+	}
+	a.mutex.Unlock()
+
+	// o hash é lento de propósito: calcula fora do lock
+	if existe {
+		if !senhaConfere(esperado, hashSegredo(segredo, sal)) {
+			return false, ErrSegredoIncorreto
+		}
+		return false, nil
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	sal = hex.EncodeToString(b)
+	novo := &Perfil{
+		EstatisticasJogador: EstatisticasJogador{Nome: nome},
+		Sal:                 sal,
+		Hash:                hashSegredo(segredo, sal),
+		CriadoEm:            time.Now(),
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, existe := a.perfis[nome]; existe {
+		return false, ErrPerfilEmUso // alguém criou o mesmo nome ao mesmo tempo
+	}
+	a.perfis[nome] = novo
+	a.alteracoes++
+	return true, nil
+}
+
+// Altera as estatísticas de um perfil (nomes sem perfil são ignorados)
+func (a *ArmazemPerfis) Registrar(nome string, alterar func(*EstatisticasJogador)) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if perfil, existe := a.perfis[nome]; existe {
+		alterar(&perfil.EstatisticasJogador)
+		a.alteracoes++
+	}
+}
+
+// Estatísticas de um perfil
+func (a *ArmazemPerfis) Estatisticas(nome string) (EstatisticasJogador, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	perfil, existe := a.perfis[nome]
+	if !existe {
+		return EstatisticasJogador{}, false
+	}
+	return perfil.EstatisticasJogador, true
+}
+
+// Os melhores perfis pelo critério, do maior pro menor
+func (a *ArmazemPerfis) Ranking(criterio string, limite int) ([]EstatisticasJogador, error) {
+	var valor func(e EstatisticasJogador) int64
+	switch criterio {
+	case RankingCorridas:
+		valor = func(e EstatisticasJogador) int64 { return int64(e.CorridasVencidas) }
+	case RankingDistancia:
+		valor = func(e EstatisticasJogador) int64 { return e.Distancia }
+	case RankingTempo:
+		valor = func(e EstatisticasJogador) int64 { return int64(e.TempoJogo) }
+	case RankingPartidas:
+		valor = func(e EstatisticasJogador) int64 { return int64(e.Partidas) }
+	case RankingInimigos:
+		valor = func(e EstatisticasJogador) int64 { return int64(e.InimigosDerrotados) }
+	default:
+		return nil, fmt.Errorf("critério de ranking desconhecido: %q", criterio)
+	}
+
+	a.mutex.Lock()
+	lista := make([]EstatisticasJogador, 0, len(a.perfis))
+	for _, perfil := range a.perfis {
+		lista = append(lista, perfil.EstatisticasJogador)
+	}
+	a.mutex.Unlock()
+
+	sort.Slice(lista, func(i, j int) bool {
+		if vi, vj := valor(lista[i]), valor(lista[j]); vi != vj {
+			return vi > vj
+		}
+		return lista[i].Nome < lista[j].Nome
+	})
+	return lista[:min(limite, len(lista))], nil
+}
+
+// Salva no arquivo se algo mudou. Só conta como salvo depois que o arquivo
+// novo está no lugar: se der erro, a próxima chamada tenta de novo.
+func (a *ArmazemPerfis) Salvar() error {
+	a.gravacao.Lock()
+	defer a.gravacao.Unlock()
+
+	a.mutex.Lock()
+	if a.alteracoes == a.salvas {
+		a.mutex.Unlock()
+		return nil
+	}
+	versao := a.alteracoes
+	dados, err := json.MarshalIndent(a.perfis, "", "  ")
+	a.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.arquivo), filepath.Base(a.arquivo)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(dados); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), a.arquivo); err != nil {
+		return err
+	}
+
+	// o que mudou enquanto gravava continua pendente pro próximo Salvar
+	a.mutex.Lock()
+	a.salvas = versao
+	a.mutex.Unlock()
+	return nil
+}
+
+// ---- servidor ----
+
+// sessão de jogo de um jogador com perfil (só o loop mexe)
+type sessaoPerfil struct {
+	perfil string
+	entrou time.Time
+}
+
+// confere o segredo do perfil com o limite de tentativas do ip
+func (gs *GameService) entrarPerfil(nome, segredo string) error {
+	ip := ipDoEndereco(gs.conexao.endereco)
+	limitador := gs.servidor.tentativas
+	if !limitador.permitido(ip) {
+		return ErrMuitasTentativas
+	}
+
+	criado, err := gs.servidor.perfis.Entrar(nome, segredo)
+	if errors.Is(err, ErrSegredoIncorreto) {
+		if limitador.falhou(ip) {
+			log.Printf("[auth] %s bloqueado por %v depois de errar o segredo do perfil %s várias vezes", ip, limitador.janela, nome)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	limitador.acertou(ip)
+	if criado {
+		log.Printf("Perfil %s criado", nome)
+	}
+	return nil
+}
+
+// fecha a sessão do perfil somando o tempo jogado (só chamado dentro do loop)
+func (gs *GameServer) encerrarSessaoPerfil(id string) {
+	sessao, existe := gs.sessoes[id]
+	if !existe {
+		return
+	}
+	delete(gs.sessoes, id)
+	tempo := time.Since(sessao.entrou).Round(time.Second)
+	gs.perfis.Registrar(sessao.perfil, func(e *EstatisticasJogador) { e.TempoJogo += tempo })
+}
+
+// o jogador chegou à saída: conta a vitória e manda ele de volta pro começo (só chamado dentro do loop)
+func (gs *GameServer) venceuCorrida(id string) {
+	jogador := gs.jogadores[id]
+	if sessao, existe := gs.sessoes[id]; existe {
+		gs.perfis.Registrar(sessao.perfil, func(e *EstatisticasJogador) { e.CorridasVencidas++ })
+	}
+	gs.anunciar(fmt.Sprintf("%s chegou à saída!", jogador.Nome))
+	log.Printf("%s venceu a corrida", jogador.Nome)
+
+	inicio := Ponto{}
+	if len(gs.spawns) > 0 {
+		inicio = gs.spawns[int(gs.tick)%len(gs.spawns)]
+	}
+	destino, _ := gs.posicaoLivre(inicio, id) // sempre acha: a célula onde ele está é livre pra ele
+	gs.reposicionar(id, destino)
+}
+
+// RPC: Estatísticas do perfil do próprio jogador (com o tempo da partida atual)
+func (gs *GameService) ObterEstatisticas(req SessaoRequest, reply *EstatisticasJogador) error {
+	if err := gs.verificarSessao(req.JogadorID, req.Token, "ObterEstatisticas"); err != nil {
+		return err
+	}
+	if gs.servidor.perfis == nil {
+		return ErrPerfisDesativados
+	}
+
+	var sessao sessaoPerfil
+	var temPerfil bool
+	gs.servidor.executar(func() {
+		sessao, temPerfil = gs.servidor.sessoes[req.JogadorID]
+	})
+	if !temPerfil {
+		return ErrSemPerfil
+	}
+
+	estatisticas, _ := gs.servidor.perfis.Estatisticas(sessao.perfil)
+	estatisticas.TempoJogo += time.Since(sessao.entrou).Round(time.Second)
+	*reply = estatisticas
+	return nil
+}
+
+// RPC: Ranking dos perfis (público)
+func (gs *GameService) Ranking(req RankingRequest, reply *RankingResponse) error {
+	if gs.servidor.perfis == nil {
+		return ErrPerfisDesativados
+	}
+	if req.Criterio == "" {
+		req.Criterio = RankingCorridas
+	}
+	if req.Limite <= 0 {
+		req.Limite = 10
+	}
+
+	perfis, err := gs.servidor.perfis.Ranking(req.Criterio, min(req.Limite, 100))
+	if err != nil {
+		return err
+	}
+	reply.Criterio = req.Criterio
+	reply.Perfis = perfis
+	return nil
+}
+
+// ---- cliente ----
+
+// Estatísticas do perfil do jogador
+func (gc *GameClient) ObterEstatisticas() (EstatisticasJogador, error) {
+	var estatisticas EstatisticasJogador
+	err := gc.client.Call("GameService.ObterEstatisticas", gc.sessao(), &estatisticas)
+	return estatisticas, err
+}
+
+// Ranking do servidor pelo critério
+func (gc *GameClient) Ranking(criterio string, limite int) (RankingResponse, error) {
+	var resp RankingResponse
+	err := gc.client.Call("GameService.Ranking", RankingRequest{Criterio: criterio, Limite: limite}, &resp)
+	return resp, err
+}
+
+// linhas do painel de estatísticas
+func LinhasEstatisticas(e EstatisticasJogador) []string {
+	return []string{
+		fmt.Sprintf("Tempo de jogo:        %v", e.TempoJogo),
+		fmt.Sprintf("Distância percorrida: %d passos", e.Distancia),
+		fmt.Sprintf("Partidas:             %d", e.Partidas),
+		fmt.Sprintf("Corridas vencidas:    %d", e.CorridasVencidas),
+		fmt.Sprintf("Inimigos derrotados:  %d", e.InimigosDerrotados),
+	}
+}
+
+// linhas do painel de ranking
+func LinhasRanking(r RankingResponse) []string {
+	linhas := make([]string, 0, len(r.Perfis))
+	for i, e := range r.Perfis {
+		linhas = append(linhas, fmt.Sprintf("%2d. %-16s corridas %3d  passos %6d  tempo %9v  partidas %3d  inimigos %3d",
+			i+1, e.Nome, e.CorridasVencidas, e.Distancia, e.TempoJogo, e.Partidas, e.InimigosDerrotados))
+	}
+	if len(linhas) == 0 {
+		linhas = append(linhas, "Nenhum perfil ainda.")
+	}
+	return linhas
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSalvarPerfisTentaDeNovoDepoisDeErro(t *testing.T) {
+	dir := t.TempDir()
+	arquivo := filepath.Join(dir, "perfis.json")
+	a, err := AbrirArmazemPerfis(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Entrar("Ana", "segredo"); err != nil {
+		t.Fatal(err)
+	}
+
+	// diretório que não existe: a gravação falha e a mudança continua pendente
+	a.arquivo = filepath.Join(dir, "sumiu", "perfis.json")
+	if err := a.Salvar(); err == nil {
+		t.Fatal("esperava erro ao salvar num diretório que não existe")
+	}
+
+	a.arquivo = arquivo
+	if err := a.Salvar(); err != nil {
+		t.Fatalf("erro ao salvar: %v", err)
+	}
+	reaberto, err := AbrirArmazemPerfis(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, existe := reaberto.Estatisticas("Ana"); !existe {
+		t.Fatal("o perfil da Ana não foi salvo depois do erro")
+	}
+
+	// sem mudanças, não grava de novo
+	os.Remove(arquivo)
+	if err := a.Salvar(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(arquivo); !os.IsNotExist(err) {
+		t.Fatalf("Salvar sem mudanças gravou o arquivo (%v)", err)
+	}
+
+	a.Registrar("Ana", func(e *EstatisticasJogador) { e.Partidas++ })
+	if err := a.Salvar(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(arquivo); err != nil {
+		t.Fatalf("a mudança nas estatísticas não foi salva: %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// versão do formato do arquivo de estado
+const versaoEstadoSalvo = 1
+
+// EstadoSalvo é o que o servidor grava em disco pra sobreviver a um reinício
+type EstadoSalvo struct {
+	Versao     int                     `json:"versao"`
+	SalvoEm    time.Time               `json:"salvo_em"`
+	Mapa       []string                `json:"mapa,omitempty"` // mapa atual, com as trocas feitas pela administração
+	VersaoMapa int64                   `json:"versao_mapa"`
+	Jogadores  map[string]JogadorSalvo `json:"jogadores"` // pelo perfil: só quem entra com segredo prova que é quem volta
+	Banidos    []string                `json:"banidos,omitempty"`
+	Aviso      string                  `json:"aviso,omitempty"`
+}
+
+// o que se guarda de cada jogador (o jogo ainda não tem inventário)
+type JogadorSalvo struct {
+	PosX    int       `json:"x"`
+	PosY    int       `json:"y"`
+	VistoEm time.Time `json:"visto_em"` // última vez que estava no jogo
+}
+
+// junta o estado atual pra salvar (só chamado dentro do loop ou com o loop parado)
+func (gs *GameServer) coletarEstado() EstadoSalvo {
+	estado := EstadoSalvo{
+		Versao:     versaoEstadoSalvo,
+		SalvoEm:    time.Now(),
+		Mapa:       gs.mapa,
+		VersaoMapa: gs.versaoMapa,
+		Jogadores:  make(map[string]JogadorSalvo, len(gs.salvos)+len(gs.jogadores)),
+		Banidos:    gs.banidos.listar(),
+		Aviso:      gs.aviso,
+	}
+	for nome, jogador := range gs.salvos {
+		estado.Jogadores[nome] = jogador
+	}
+	// quem está online agora vale mais que o que foi guardado quando saiu
+	for id, jogador := range gs.jogadores {
+		if sessao, existe := gs.sessoes[id]; existe {
+			estado.Jogadores[sessao.perfil] = JogadorSalvo{PosX: jogador.PosX, PosY: jogador.PosY, VistoEm: estado.SalvoEm}
+		}
+	}
+	return estado
+}
+
+// Guarda a posição de quem está saindo pra quando voltar (só chamado dentro do loop).
+// Só vale pra quem entrou num perfil: qualquer um pode usar qualquer nome, então
+// sem o segredo a posição de um jogador ficaria pra quem pegasse o nome dele.
+func (gs *GameServer) lembrarJogador(id string, jogador PosicaoJogador) {
+	sessao, existe := gs.sessoes[id]
+	if gs.config.ArquivoEstado == "" || !existe {
+		return
+	}
+	gs.salvos[sessao.perfil] = JogadorSalvo{PosX: jogador.PosX, PosY: jogador.PosY, VistoEm: time.Now()}
+}
+
+// Salva o estado atual no arquivo configurado
+func (gs *GameServer) SalvarEstado() error {
+	var estado EstadoSalvo
+	select {
+	case <-gs.parado:
+		estado = gs.coletarEstado() // o loop já parou: ninguém mais mexe no estado
+	default:
+		gs.executar(func() { estado = gs.coletarEstado() })
+	}
+	if estado.Versao == 0 {
+		return ErrServidorDesligado // o loop parou antes de coletar: o desligamento salva
+	}
+	return SalvarEstadoArquivo(gs.config.ArquivoEstado, estado)
+}
+
+// SalvarEstadoArquivo grava o estado em JSON sem deixar o arquivo pela metade se o processo cair
+func SalvarEstadoArquivo(nome string, estado EstadoSalvo) error {
+	dados, err := json.MarshalIndent(estado, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(nome), filepath.Base(nome)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // não faz nada depois do Rename
+	if _, err := tmp.Write(dados); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), nome)
+}
+
+// CarregarEstadoArquivo lê um estado salvo; arquivo que não existe não é erro (devolve nil)
+func CarregarEstadoArquivo(nome string) (*EstadoSalvo, error) {
+	dados, err := os.ReadFile(nome)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var estado EstadoSalvo
+	if err := json.Unmarshal(dados, &estado); err != nil {
+		return nil, fmt.Errorf("arquivo de estado %s inválido: %w", nome, err)
+	}
+	if estado.Versao != versaoEstadoSalvo {
+		return nil, fmt.Errorf("arquivo de estado %s tem versão %d, esperada %d", nome, estado.Versao, versaoEstadoSalvo)
+	}
+	return &estado, nil
+}
+
+// aplica um estado salvo num servidor que ainda não começou o loop
+func (gs *GameServer) restaurarEstado(estado *EstadoSalvo) error {
+	if len(estado.Mapa) > 0 {
+		jogo := &Jogo{}
+		if err := CarregarMapaDeLinhas(estado.Mapa, jogo); err != nil {
+			return err
+		}
+		gs.mapa = estado.Mapa
+		gs.spawns = jogo.Spawns
+		gs.elementos = jogo.Mapa
+	}
+	gs.versaoMapa = estado.VersaoMapa
+	for nome, jogador := range estado.Jogadores {
+		gs.salvos[nome] = jogador
+	}
+	for _, alvo := range estado.Banidos {
+		gs.banidos.adicionar(alvo)
+	}
+	gs.aviso = estado.Aviso
+	return nil
+}
+
+// salva o estado do mundo e os perfis, o que estiver ligado
+func (gs *GameServer) salvarTudo() error {
+	if gs.config.ArquivoEstado != "" {
+		if err := gs.SalvarEstado(); err != nil {
+			return fmt.Errorf("erro ao salvar estado: %w", err)
+		}
+	}
+	if gs.perfis != nil {
+		if err := gs.perfis.Salvar(); err != nil {
+			return fmt.Errorf("erro ao salvar perfis: %w", err)
+		}
+	}
+	return nil
+}
+
+// salva de tempos em tempos até o servidor desligar (o desligamento salva uma última vez)
+func (gs *GameServer) loopPersistencia() {
+	ticker := time.NewTicker(gs.config.IntervaloSalvamento)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gs.parar:
+			return
+		case <-ticker.C:
+			if err := gs.salvarTudo(); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
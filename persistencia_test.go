@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPosicaoSalvaSoVoltaProDonoDoPerfil(t *testing.T) {
+	dir := t.TempDir()
+	cfg := ConfigServidorPadrao
+	cfg.ArquivoEstado = filepath.Join(dir, "mundo.json")
+	cfg.ArquivoPerfis = filepath.Join(dir, "perfis.json")
+
+	entrar := func(servico *GameService, nome, segredo string) ConectarPosicaoResponse {
+		t.Helper()
+		var resp ConectarPosicaoResponse
+		if err := servico.ConectarJogo(ConectarRequest{Nome: nome, Segredo: segredo}, &resp); err != nil {
+			t.Fatalf("erro ao conectar %s: %v", nome, err)
+		}
+		return resp
+	}
+	posicao := func(gs *GameServer, resp ConectarPosicaoResponse) Ponto {
+		jogador := gs.snapshot.Load().jogadores[resp.JogadorID]
+		return Ponto{jogador.PosX, jogador.PosY}
+	}
+
+	// primeira execução: a Ana (com perfil) sai do jogo e a Bia (sem perfil) fica até o desligamento
+	gs := servidorDeTeste(t, cfg)
+	servico := servicoDeTeste(t, gs)
+	ana := entrar(servico, "Ana", "segredo")
+	bia := entrar(servico, "Bia", "")
+	if err := gs.Teleportar(ana.JogadorID, 20, 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.Teleportar(bia.JogadorID, 30, 30); err != nil {
+		t.Fatal(err)
+	}
+	var ok bool
+	if err := servico.Desconectar(SessaoRequest{JogadorID: ana.JogadorID, Token: ana.Token}, &ok); err != nil {
+		t.Fatal(err)
+	}
+	gs.Desligar("reinício", 0)
+
+	estado, err := CarregarEstadoArquivo(cfg.ArquivoEstado)
+	if err != nil || estado == nil {
+		t.Fatalf("estado não foi salvo: %v", err)
+	}
+	if salvo, existe := estado.Jogadores["Ana"]; !existe || salvo.PosX != 20 || salvo.PosY != 20 {
+		t.Fatalf("posição da Ana no arquivo = %+v, %v; esperava (20, 20)", salvo, existe)
+	}
+	if _, existe := estado.Jogadores["Bia"]; existe {
+		t.Fatal("a posição da Bia, que entrou sem perfil, foi salva")
+	}
+
+	// segunda execução com os mesmos arquivos
+	gs = servidorDeTeste(t, cfg)
+	servico = servicoDeTeste(t, gs)
+
+	impostor := entrar(servico, "Ana", "")
+	if p := posicao(gs, impostor); p == (Ponto{20, 20}) {
+		t.Fatal("quem entrou com o nome Ana sem o segredo ficou com a posição salva dela")
+	}
+	ana = entrar(servico, "Ana", "segredo")
+	if p := posicao(gs, ana); p != (Ponto{20, 20}) {
+		t.Fatalf("a Ana voltou em %v, esperava (20, 20)", p)
+	}
+	bia = entrar(servico, "Bia", "")
+	if p := posicao(gs, bia); p == (Ponto{30, 30}) {
+		t.Fatal("a Bia, sem perfil, voltou pra posição antiga")
+	}
+}
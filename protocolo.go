@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/rpc"
+	"strings"
+	"time"
+)
+
+// Tudo que passa pela rede entre cliente e servidor fica neste arquivo: são
+// os tipos do protocolo, separados dos tipos do jogo local (Elemento, Cor,
+// Jogador), que dependem do termbox. As regras pra mudar estes tipos sem
+// quebrar quem já está usando estão em PROTOCOLO.md, em "Versões".
+
+// versões do protocolo
+const (
+	// versão que este código fala; sobe a cada mudança incompatível
+	VersaoProtocolo = 4
+
+	// versão mais antiga que ainda é aceita do outro lado (cliente pelo
+	// servidor e servidor pelo cliente)
+	VersaoMinimaProtocolo = 2
+
+	// primeira versão com a RPC Comando
+	VersaoComandos = 3
+
+	// primeira versão com a RPC Lote
+	VersaoLote = 4
+)
+
+// erros do handshake
+var (
+	ErrProtocoloIncompativel = errors.New("versão de protocolo incompatível")
+	ErrSemHandshake          = errors.New("cliente sem handshake de protocolo, atualize o cliente")
+	ErrServidorAntigo        = errors.New("o servidor é de uma versão antiga, sem handshake de protocolo")
+)
+
+// primeira RPC de toda conexão: cada lado diz que versão fala
+type HandshakeRequest struct {
+	Versao   int    // VersaoProtocolo do cliente
+	Programa string // nome e versão do programa cliente, só pro log
+}
+
+type HandshakeResponse struct {
+	Versao       int // VersaoProtocolo do servidor
+	VersaoMinima int // versão mais antiga de cliente que o servidor aceita
+}
+
+// RPC: combina a versão do protocolo; sem ela a conexão não entra no jogo nem na administração
+func (gs *GameService) Handshake(req HandshakeRequest, reply *HandshakeResponse) error {
+	reply.Versao = VersaoProtocolo
+	reply.VersaoMinima = VersaoMinimaProtocolo
+
+	if req.Versao < VersaoMinimaProtocolo {
+		log.Printf("Recusando %s (%s): protocolo %d, aceito de %d a %d",
+			gs.conexao.endereco, req.Programa, req.Versao, VersaoMinimaProtocolo, VersaoProtocolo)
+		return fmt.Errorf("%w: o cliente fala a versão %d e o servidor aceita da %d em diante, atualize o cliente",
+			ErrProtocoloIncompativel, req.Versao, VersaoMinimaProtocolo)
+	}
+	gs.conexao.definirVersao(min(req.Versao, VersaoProtocolo)) // fala a mais nova que os dois conhecem
+	return nil
+}
+
+// confere se a conexão já fez o handshake
+func (gs *GameService) exigirHandshake() error {
+	if gs.conexao.versaoProtocolo() == 0 {
+		return ErrSemHandshake
+	}
+	return nil
+}
+
+// Faz o handshake pelo lado do cliente, recusando servidores antigos demais.
+// Devolve a versão que os dois lados falam, pra saber que métodos o servidor tem.
+func fazerHandshake(client *rpc.Client, programa string) (int, error) {
+	var resp HandshakeResponse
+	err := client.Call("GameService.Handshake", HandshakeRequest{Versao: VersaoProtocolo, Programa: programa}, &resp)
+	var errServidor rpc.ServerError
+	if errors.As(err, &errServidor) && strings.HasPrefix(err.Error(), "rpc: can't find method") {
+		return 0, ErrServidorAntigo
+	}
+	if err != nil {
+		return 0, err
+	}
+	if resp.Versao < VersaoMinimaProtocolo {
+		return 0, fmt.Errorf("%w: o servidor fala a versão %d e este cliente precisa da %d em diante, atualize o servidor",
+			ErrProtocoloIncompativel, resp.Versao, VersaoMinimaProtocolo)
+	}
+	return min(resp.Versao, VersaoProtocolo), nil
+}
+
+// cor de jogador no protocolo: um número pequeno e fixo, sem depender do termbox.
+// No JSON (e no gob) vai pelo nome, que é o que os clientes devem usar.
+type CorRede uint8
+
+const (
+	CorRedeNenhuma CorRede = iota // não informada, ou uma cor nova que este código não conhece
+	CorRedeBranco
+	CorRedeVermelho
+	CorRedeVerde
+	CorRedeAzul
+	CorRedeAmarelo
+	CorRedeMagenta
+	CorRedeCiano
+)
+
+// nomes das cores no protocolo, na ordem das constantes
+var nomesCorRede = []string{"", "branco", "vermelho", "verde", "azul", "amarelo", "magenta", "ciano"}
+
+// cores que os jogadores podem ter, na ordem em que são distribuídas
+var CoresJogadores = []CorRede{
+	CorRedeBranco, CorRedeVermelho, CorRedeVerde, CorRedeAzul,
+	CorRedeAmarelo, CorRedeMagenta, CorRedeCiano,
+}
+
+func (c CorRede) String() string {
+	if int(c) < len(nomesCorRede) {
+		return nomesCorRede[c]
+	}
+	return ""
+}
+
+func (c CorRede) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// nome desconhecido vira CorRedeNenhuma em vez de erro: um servidor mais novo pode ter cores novas
+func (c *CorRede) UnmarshalText(texto []byte) error {
+	*c = CorRedeNenhuma
+	for i, nome := range nomesCorRede {
+		if nome == string(texto) {
+			*c = CorRede(i)
+		}
+	}
+	return nil
+}
+
+// cor do termbox usada pra desenhar (cor desconhecida aparece em branco)
+func (c CorRede) Terminal() Cor {
+	switch c {
+	case CorRedeVermelho:
+		return CorVermelho
+	case CorRedeVerde:
+		return CorVerde
+	case CorRedeAzul:
+		return CorAzul
+	case CorRedeAmarelo:
+		return CorAmarelo
+	case CorRedeMagenta:
+		return CorMagenta
+	case CorRedeCiano:
+		return CorCyan
+	}
+	return CorBranco
+}
+
+// estrutura usada quando o jogador se conecta
+type ConectarRequest struct {
+	MapaFile   string // arquivo do mapa que o cliente quer usar
+	Nome       string // nome do jogador que está se conectando
+	Senha      string // senha do servidor (se ele exigir)
+	Segredo    string // segredo do perfil do jogador (vazio = joga sem perfil)
+	Espectador bool   // só assiste: não ganha personagem
+}
+
+// Nova estrutura para resposta do servidor com apenas as posições
+type ConectarPosicaoResponse struct {
+	JogadorID string            // id que o servidor gerou pro jogador
+	Token     string            // segredo da sessão: exigido em todas as RPCs seguintes
+	Posicoes  PosicoesJogadores // posições dos jogadores
+	Mapa      []string          // linhas do mapa do servidor (vazio = cliente usa o arquivo local)
+}
+
+// estrutura usada quando o jogador quer se mover
+type MoverRequest struct {
+	JogadorID      string
+	Token          string // token secreto da sessão recebido no ConectarJogo
+	SequenceNumber int64
+	Tecla          rune
+}
+
+// identifica a sessão do jogador nas RPCs que não têm dados próprios
+type SessaoRequest struct {
+	JogadorID string
+	Token     string // token secreto da sessão recebido no ConectarJogo
+}
+
+// mandar comandos do cliente pro servidor (RPC Comando, desde a versão 3)
+type ComandoRequest struct {
+	JogadorID      string
+	Token          string          // token secreto da sessão
+	SequenceNumber int64           // mesma sequência do Mover: repetido ou atrasado é ignorado
+	Comando        string          // nome do comando (ComandoMover, ComandoChat...)
+	Dados          json.RawMessage // dados do comando em JSON, no formato que o comando espera (pode faltar)
+}
+
+// nomes dos comandos que o servidor conhece
+const (
+	ComandoMover     = "mover"     // DadosMover
+	ComandoInteragir = "interagir" // sem dados: derrota um inimigo ao lado
+	ComandoChat      = "chat"      // DadosChat
+	ComandoEmote     = "emote"     // DadosEmote
+)
+
+type DadosMover struct {
+	Tecla rune // w, a, s ou d
+}
+
+type DadosChat struct {
+	Texto string
+}
+
+type DadosEmote struct {
+	Emote string // um dos nomes de SimbolosEmote
+}
+
+// emotes que o jogador pode mostrar e o símbolo de cada um (os clientes podem desenhar como quiserem)
+var SimbolosEmote = map[string]rune{
+	"feliz":    '☻',
+	"triste":   '☹',
+	"amor":     '♥',
+	"duvida":   '?',
+	"surpresa": '!',
+	"musica":   '♪',
+}
+
+// resultado de um comando: igual pra todos, dê certo ou não
+type ResultadoComando struct {
+	SequenceNumber int64
+	Comando        string
+	Aplicado       bool            // o servidor aplicou o comando
+	Duplicado      bool            // já tinha aplicado esse SequenceNumber (não é erro: o cliente pode ter repetido)
+	Erro           string          // por que não aplicou (vazio = sem erro)
+	Resposta       json.RawMessage // dados que o comando devolve, em JSON (pode faltar)
+}
+
+type ComandoResponse struct {
+	Resultado ResultadoComando
+	Posicoes  PosicoesJogadores // posições depois do comando
+}
+
+// vários comandos de uma vez (RPC Lote, desde a versão 4): entram todos ou
+// nenhum e são aplicados em ordem no mesmo tick, sem nada no meio
+type LoteRequest struct {
+	JogadorID string
+	Token     string        // token secreto da sessão
+	Comandos  []ComandoLote // em ordem, com SequenceNumber crescente
+}
+
+// um comando do lote: o mesmo que o ComandoRequest, sem a sessão
+type ComandoLote struct {
+	SequenceNumber int64
+	Comando        string
+	Dados          json.RawMessage
+}
+
+type LoteResponse struct {
+	Resultados []ResultadoComando // um por comando, na ordem do pedido
+	Posicoes   PosicoesJogadores  // posições depois do lote
+}
+
+// mensagem de chat guardada pelo servidor
+type MensagemChat struct {
+	ID        int64  // cresce a cada mensagem: o cliente mostra as que ainda não viu
+	JogadorID string // quem mandou
+	Nome      string
+	Texto     string
+}
+
+// Nova estrutura para armazenar apenas as posições dos jogadores
+type PosicoesJogadores struct {
+	Jogadores        map[string]PosicaoJogador // posições de todos os jogadores
+	JogadorID        string                    // id do jogador atual
+	UltimoProcessado int64                     // último comando processado
+	Tick             int64                     // tick do servidor em que as posições foram tiradas
+	Aviso            string                    // mensagem da administração pra todos (vazio = nenhuma)
+	AvisoID          int64                     // muda a cada aviso novo, mesmo se o texto repetir
+	VersaoMapa       int64                     // muda quando o servidor troca de mapa
+	Desligamento     string                    // motivo, se o servidor estiver desligando (vazio = normal)
+	DesligaEm        time.Duration             // quanto falta pro servidor fechar as conexões
+	Chat             []MensagemChat            // últimas mensagens de chat, da mais antiga pra mais nova
+}
+
+// Estrutura minimalista para representar a posição de um jogador
+type PosicaoJogador struct {
+	ID        string  // id único do jogador
+	Nome      string  // nome do jogador
+	PosX      int     // posição x no mapa
+	PosY      int     // posição y no mapa
+	Cor       CorRede // cor do jogador
+	Simbolo   rune    // símbolo que representa o jogador
+	Conectado bool    // se está conectado ou não
+	Reposicao int64   // muda quando o servidor move o jogador à força (teleporte, troca de mapa)
+	Emote     string  // emote que o jogador está mostrando (vazio = nenhum)
+}
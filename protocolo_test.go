@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+)
+
+func TestHandshakeServidor(t *testing.T) {
+	casos := []struct {
+		nome   string
+		versao int // versão mandada pelo cliente
+		erro   error
+		falada int // versão guardada na conexão (0 = recusada)
+	}{
+		{nome: "cliente sem versão", versao: 0, erro: ErrProtocoloIncompativel},
+		{nome: "cliente antigo demais", versao: VersaoMinimaProtocolo - 1, erro: ErrProtocoloIncompativel},
+		{nome: "versão mínima", versao: VersaoMinimaProtocolo, falada: VersaoMinimaProtocolo},
+		{nome: "cliente sem lotes", versao: VersaoLote - 1, falada: VersaoLote - 1},
+		{nome: "mesma versão", versao: VersaoProtocolo, falada: VersaoProtocolo},
+		{nome: "cliente mais novo", versao: VersaoProtocolo + 3, falada: VersaoProtocolo},
+	}
+
+	gs := servidorDeTeste(t, ConfigServidorPadrao)
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			local, remoto := net.Pipe()
+			defer remoto.Close()
+			servico := &GameService{servidor: gs, conexao: novaConexaoCliente(local)}
+
+			var resp HandshakeResponse
+			err := servico.Handshake(HandshakeRequest{Versao: c.versao, Programa: "teste"}, &resp)
+			if !errors.Is(err, c.erro) {
+				t.Fatalf("Handshake = %v, esperava %v", err, c.erro)
+			}
+			if resp.Versao != VersaoProtocolo || resp.VersaoMinima != VersaoMinimaProtocolo {
+				t.Errorf("resposta %+v, esperava versão %d e mínima %d", resp, VersaoProtocolo, VersaoMinimaProtocolo)
+			}
+			if v := servico.conexao.versaoProtocolo(); v != c.falada {
+				t.Errorf("a conexão ficou com a versão %d, esperava %d", v, c.falada)
+			}
+
+			// sem handshake aceito a conexão não entra no jogo
+			var conectar ConectarPosicaoResponse
+			err = servico.ConectarJogo(ConectarRequest{Nome: "Ana"}, &conectar)
+			if c.erro != nil && !errors.Is(err, ErrSemHandshake) {
+				t.Errorf("ConectarJogo depois do handshake recusado = %v, esperava ErrSemHandshake", err)
+			}
+			if c.erro == nil && err != nil {
+				t.Errorf("ConectarJogo depois do handshake = %v", err)
+			}
+		})
+	}
+}
+
+// servidor RPC de mentira que responde o handshake com uma versão fixa
+type servidorHandshake struct{ versao int }
+
+func (s *servidorHandshake) Handshake(req HandshakeRequest, reply *HandshakeResponse) error {
+	reply.Versao = s.versao
+	reply.VersaoMinima = min(s.versao, VersaoMinimaProtocolo)
+	return nil
+}
+
+// servidor de antes do handshake: tem o GameService, mas sem o método
+type servidorSemHandshake struct{}
+
+func (servidorSemHandshake) ObterPosicoes(req SessaoRequest, reply *PosicoesJogadores) error {
+	return nil
+}
+
+// cliente RPC ligado por um net.Pipe a um servidor que registra o serviço como GameService
+func clienteHandshake(t *testing.T, servico any) *rpc.Client {
+	t.Helper()
+	servidor := rpc.NewServer()
+	if err := servidor.RegisterName("GameService", servico); err != nil {
+		t.Fatal(err)
+	}
+	local, remoto := net.Pipe()
+	go servidor.ServeConn(remoto)
+	client := rpc.NewClient(local)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestFazerHandshake(t *testing.T) {
+	casos := []struct {
+		nome    string
+		servico any
+		versao  int
+		erro    error
+	}{
+		{nome: "servidor sem handshake", servico: servidorSemHandshake{}, erro: ErrServidorAntigo},
+		{nome: "servidor antigo demais", servico: &servidorHandshake{versao: VersaoMinimaProtocolo - 1}, erro: ErrProtocoloIncompativel},
+		{nome: "servidor sem lotes", servico: &servidorHandshake{versao: VersaoLote - 1}, versao: VersaoLote - 1},
+		{nome: "mesma versão", servico: &servidorHandshake{versao: VersaoProtocolo}, versao: VersaoProtocolo},
+		{nome: "servidor mais novo", servico: &servidorHandshake{versao: VersaoProtocolo + 1}, versao: VersaoProtocolo},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			versao, err := fazerHandshake(clienteHandshake(t, c.servico), "teste")
+			if !errors.Is(err, c.erro) {
+				t.Fatalf("fazerHandshake = %v, esperava %v", err, c.erro)
+			}
+			if versao != c.versao {
+				t.Fatalf("versão combinada %d, esperava %d", versao, c.versao)
+			}
+		})
+	}
+}
+
+func TestFazerHandshakeComOServidorDeVerdade(t *testing.T) {
+	gs := servidorDeTeste(t, ConfigServidorPadrao)
+	local, remoto := net.Pipe()
+	defer remoto.Close()
+	conexao := novaConexaoCliente(local)
+
+	versao, err := fazerHandshake(clienteHandshake(t, &GameService{servidor: gs, conexao: conexao}), "teste")
+	if err != nil {
+		t.Fatalf("fazerHandshake = %v", err)
+	}
+	if versao != VersaoProtocolo || conexao.versaoProtocolo() != VersaoProtocolo {
+		t.Fatalf("cliente combinou %d e o servidor guardou %d, esperava %d nos dois",
+			versao, conexao.versaoProtocolo(), VersaoProtocolo)
+	}
+}
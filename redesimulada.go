@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Rede simulada pra desenvolvimento: embrulha a conexão do net/rpc (no cliente,
+// no Dial; no servidor, em cada conexão aceita) e atrasa, limita e derruba o
+// tráfego como uma rede ruim faria. Serve pra ver a previsão, a reconexão e os
+// números de sequência funcionando sem sair do localhost.
+//
+// O atraso vale pros dois sentidos da conexão embrulhada, então ligando de um
+// lado só o tempo de ida e volta fica em 2x a latência. A ordem dos bytes nunca
+// muda (é TCP): a variação só atrasa mais ou menos cada escrita.
+
+var ErrQuedaSimulada = errors.New("conexão derrubada pela rede simulada")
+
+// condições da rede simulada (tudo zero = rede normal, sem embrulho)
+type RedeSimulada struct {
+	Latencia    time.Duration // atraso de cada pedaço de dados, em cada sentido
+	Variacao    time.Duration // jitter: até isso a mais ou a menos na latência, sorteado
+	Banda       int           // bytes por segundo em cada sentido (0 = sem limite)
+	QuedaApos   time.Duration // derruba a conexão depois desse tempo (0 = nunca)
+	ChanceQueda float64       // chance de derrubar a conexão a cada escrita (0 a 1)
+	Semente     int64         // semente dos sorteios: a mesma semente repete os mesmos atrasos e quedas
+}
+
+// conexões embrulhadas até agora: a n-ésima conexão sorteia sempre a mesma coisa
+var conexoesSimuladas atomic.Int64
+
+// diz se alguma condição foi configurada
+func (r RedeSimulada) Ativa() bool {
+	return r.Latencia > 0 || r.Variacao > 0 || r.Banda > 0 || r.QuedaApos > 0 || r.ChanceQueda > 0
+}
+
+// descrição curta pro log
+func (r RedeSimulada) String() string {
+	var partes []string
+	if r.Latencia > 0 || r.Variacao > 0 {
+		partes = append(partes, fmt.Sprintf("latência %v ± %v", r.Latencia, r.Variacao))
+	}
+	if r.Banda > 0 {
+		partes = append(partes, fmt.Sprintf("banda %d B/s", r.Banda))
+	}
+	if r.QuedaApos > 0 {
+		partes = append(partes, fmt.Sprintf("queda após %v", r.QuedaApos))
+	}
+	if r.ChanceQueda > 0 {
+		partes = append(partes, fmt.Sprintf("%.1f%% de queda por escrita", r.ChanceQueda*100))
+	}
+	return strings.Join(partes, ", ")
+}
+
+// embrulha a conexão se alguma condição foi configurada (senão devolve ela mesma)
+func (r RedeSimulada) Embrulhar(conn net.Conn) net.Conn {
+	if !r.Ativa() {
+		return conn
+	}
+
+	c := &conexaoSimulada{
+		Conn:    conn,
+		rede:    r,
+		sorteio: rand.New(rand.NewSource(r.Semente + conexoesSimuladas.Add(1))),
+		saida:   sentidoSimulado{pacotes: make(chan pacoteSimulado, 64)},
+		entrada: sentidoSimulado{pacotes: make(chan pacoteSimulado, 64)},
+		fechada: make(chan struct{}),
+		caiu:    make(chan struct{}),
+	}
+	go c.escrever()
+	go c.ler()
+	if r.QuedaApos > 0 {
+		c.temporizador = time.AfterFunc(r.QuedaApos, func() { c.derrubar(ErrQuedaSimulada) })
+	}
+	return c
+}
+
+// um pedaço de dados a caminho, com a hora em que chega do outro lado
+type pacoteSimulado struct {
+	dados   []byte
+	entrega time.Time
+	err     error // erro da conexão de verdade (só na entrada)
+}
+
+// um sentido da conexão: pedaços em ordem e quando o "fio" fica livre
+type sentidoSimulado struct {
+	pacotes chan pacoteSimulado
+	livre   time.Time // fim da transmissão do último pedaço (limite de banda)
+	ultima  time.Time // entrega do último pedaço (nenhum chega antes dele)
+}
+
+// net.Conn com atraso, limite de banda e quedas
+type conexaoSimulada struct {
+	net.Conn
+	rede         RedeSimulada
+	mutex        sync.Mutex // protege sorteio, os horários dos sentidos e erro
+	sorteio      *rand.Rand
+	saida        sentidoSimulado
+	entrada      sentidoSimulado
+	resto        []byte // parte do último pedaço recebido que o Read ainda não devolveu
+	erroLeitura  error  // erro da conexão de verdade, devolvido depois dos dados que vieram antes dele
+	erro         error  // por que caiu
+	fechada      chan struct{}
+	caiu         chan struct{}
+	fecharUma    sync.Once
+	cairUma      sync.Once
+	temporizador *time.Timer
+}
+
+// calcula quando um pedaço de n bytes termina de sair e quando chega do outro lado
+func (c *conexaoSimulada) agendar(s *sentidoSimulado, n int) (transmitido, entrega time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	inicio := time.Now()
+	if s.livre.After(inicio) {
+		inicio = s.livre // ainda está passando o pedaço anterior
+	}
+	s.livre = inicio
+	if c.rede.Banda > 0 {
+		s.livre = inicio.Add(time.Duration(n) * time.Second / time.Duration(c.rede.Banda))
+	}
+
+	atraso := c.rede.Latencia
+	if c.rede.Variacao > 0 {
+		atraso += time.Duration(c.sorteio.Int63n(int64(2*c.rede.Variacao)+1)) - c.rede.Variacao
+	}
+	entrega = s.livre.Add(max(atraso, 0))
+	if entrega.Before(s.ultima) {
+		entrega = s.ultima // é TCP: o pedaço de trás não passa o da frente
+	}
+	s.ultima = entrega
+	return s.livre, entrega
+}
+
+// sorteia se esta escrita derruba a conexão
+func (c *conexaoSimulada) sortearQueda() bool {
+	if c.rede.ChanceQueda <= 0 {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.sorteio.Float64() < c.rede.ChanceQueda
+}
+
+// espera até a hora; false se a conexão caiu antes
+func (c *conexaoSimulada) esperar(hora time.Time) bool {
+	espera := time.Until(hora)
+	if espera <= 0 {
+		return true
+	}
+	t := time.NewTimer(espera)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-c.caiu:
+		return false
+	}
+}
+
+// Põe os dados no caminho. Volta quando eles "saíram" (limite de banda), sem esperar a latência.
+func (c *conexaoSimulada) Write(p []byte) (int, error) {
+	select {
+	case <-c.caiu:
+		return 0, c.motivoQueda()
+	case <-c.fechada:
+		return 0, c.erroFechada()
+	default:
+	}
+	if c.sortearQueda() {
+		c.derrubar(ErrQuedaSimulada)
+		return 0, ErrQuedaSimulada
+	}
+
+	transmitido, entrega := c.agendar(&c.saida, len(p))
+	if !c.esperar(transmitido) {
+		return 0, c.motivoQueda()
+	}
+	select {
+	case c.saida.pacotes <- pacoteSimulado{dados: slices.Clone(p), entrega: entrega}:
+		return len(p), nil
+	case <-c.caiu:
+		return 0, c.motivoQueda()
+	case <-c.fechada:
+		return 0, c.erroFechada()
+	}
+}
+
+// entrega os pedaços escritos na hora de cada um; no fechamento normal entrega o que já estava a caminho
+func (c *conexaoSimulada) escrever() {
+	defer c.Conn.Close()
+	for {
+		var pacote pacoteSimulado
+		select {
+		case pacote = <-c.saida.pacotes:
+		case <-c.caiu:
+			return
+		case <-c.fechada:
+			select {
+			case pacote = <-c.saida.pacotes:
+			default:
+				return
+			}
+		}
+		if !c.esperar(pacote.entrega) {
+			return
+		}
+		if _, err := c.Conn.Write(pacote.dados); err != nil {
+			return
+		}
+	}
+}
+
+// lê da conexão de verdade e marca quando cada pedaço "chega"
+func (c *conexaoSimulada) ler() {
+	for {
+		buf := make([]byte, 32*1024)
+		n, err := c.Conn.Read(buf)
+		pacote := pacoteSimulado{dados: buf[:n], err: err}
+		_, pacote.entrega = c.agendar(&c.entrada, n)
+		select {
+		case c.entrada.pacotes <- pacote:
+		case <-c.fechada:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Devolve os dados recebidos só depois da hora de entrega deles
+func (c *conexaoSimulada) Read(p []byte) (int, error) {
+	for len(c.resto) == 0 {
+		if c.erroLeitura != nil {
+			return 0, c.erroLeitura
+		}
+		select {
+		case <-c.caiu:
+			return 0, c.motivoQueda() // o que estava a caminho se perdeu
+		case <-c.fechada:
+			return 0, c.erroFechada()
+		case pacote := <-c.entrada.pacotes:
+			if !c.esperar(pacote.entrega) {
+				return 0, c.motivoQueda()
+			}
+			c.resto = pacote.dados
+			c.erroLeitura = pacote.err
+		}
+	}
+	n := copy(p, c.resto)
+	c.resto = c.resto[n:]
+	return n, nil
+}
+
+// Fecha a conexão depois de entregar o que já foi escrito
+func (c *conexaoSimulada) Close() error {
+	err := net.ErrClosed
+	c.fecharUma.Do(func() {
+		if c.temporizador != nil {
+			c.temporizador.Stop()
+		}
+		close(c.fechada)
+		err = nil
+	})
+	return err
+}
+
+// derruba a conexão na hora, perdendo o que estava a caminho
+func (c *conexaoSimulada) derrubar(motivo error) {
+	c.cairUma.Do(func() {
+		c.mutex.Lock()
+		c.erro = motivo
+		c.mutex.Unlock()
+
+		log.Printf("[rede simulada] derrubando a conexão com %s", c.RemoteAddr())
+		close(c.caiu)
+		c.Close()
+		c.Conn.Close()
+	})
+}
+
+// erro pra quem usa a conexão depois de fechada: o motivo da queda, se foi ela que
+// fechou (a queda também fecha, e o select escolheria entre os dois ao acaso)
+func (c *conexaoSimulada) erroFechada() error {
+	select {
+	case <-c.caiu:
+		return c.motivoQueda()
+	default:
+		return net.ErrClosed
+	}
+}
+
+func (c *conexaoSimulada) motivoQueda() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.erro
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// conexão embrulhada e a ponta de verdade do outro lado
+func conexaoSimuladaDeTeste(t *testing.T, rede RedeSimulada) (simulada, outra net.Conn) {
+	t.Helper()
+	local, remoto := net.Pipe()
+	simulada = rede.Embrulhar(local)
+	t.Cleanup(func() { simulada.Close(); remoto.Close() })
+	return simulada, remoto
+}
+
+func TestRedeSimuladaInativaNaoEmbrulha(t *testing.T) {
+	local, remoto := net.Pipe()
+	defer local.Close()
+	defer remoto.Close()
+	if conn := (RedeSimulada{Semente: 7}).Embrulhar(local); conn != local {
+		t.Fatalf("sem nenhuma condição a conexão foi embrulhada: %T", conn)
+	}
+}
+
+func TestRedeSimuladaMantemAOrdem(t *testing.T) {
+	rede := RedeSimulada{Latencia: 10 * time.Millisecond, Variacao: 10 * time.Millisecond, Semente: 1}
+	const pedacos = 40
+
+	casos := []struct {
+		nome string
+		// quem escreve e quem lê (um dos dois é a ponta simulada)
+		pontas func(simulada, outra net.Conn) (escreve, le net.Conn)
+	}{
+		{"enviando", func(simulada, outra net.Conn) (net.Conn, net.Conn) { return simulada, outra }},
+		{"recebendo", func(simulada, outra net.Conn) (net.Conn, net.Conn) { return outra, simulada }},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			escreve, le := c.pontas(conexaoSimuladaDeTeste(t, rede))
+
+			inicio := time.Now()
+			go func() {
+				for i := range pedacos {
+					if _, err := fmt.Fprintf(escreve, "%03d;", i); err != nil {
+						return
+					}
+				}
+			}()
+
+			// cada escrita teve um atraso sorteado, mas os dados chegam na ordem
+			recebido := make([]byte, 4*pedacos)
+			if _, err := io.ReadFull(le, recebido); err != nil {
+				t.Fatalf("erro ao ler: %v", err)
+			}
+			for i := range pedacos {
+				if pedaco, esperado := string(recebido[4*i:4*i+4]), fmt.Sprintf("%03d;", i); pedaco != esperado {
+					t.Fatalf("pedaço %d = %q, esperava %q", i, pedaco, esperado)
+				}
+			}
+			if passou := time.Since(inicio); passou < rede.Latencia-rede.Variacao {
+				t.Errorf("os dados chegaram em %v, antes da latência mínima", passou)
+			}
+		})
+	}
+}
+
+func TestRedeSimuladaQuedas(t *testing.T) {
+	casos := []struct {
+		nome string
+		rede RedeSimulada
+	}{
+		{"chance de queda", RedeSimulada{ChanceQueda: 1}},
+		{"queda depois de um tempo", RedeSimulada{QuedaApos: 20 * time.Millisecond, Latencia: time.Hour}},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			simulada, outra := conexaoSimuladaDeTeste(t, c.rede)
+
+			// com chance 1 a primeira escrita já derruba; com latência de uma hora ela fica a caminho e se perde
+			simulada.Write([]byte("perdido"))
+
+			// a leitura parada volta com o motivo quando a conexão cai
+			if _, err := simulada.Read(make([]byte, 8)); !errors.Is(err, ErrQuedaSimulada) {
+				t.Fatalf("Read depois da queda = %v, esperava ErrQuedaSimulada", err)
+			}
+			if _, err := simulada.Write([]byte("depois")); !errors.Is(err, ErrQuedaSimulada) {
+				t.Fatalf("Write depois da queda = %v, esperava ErrQuedaSimulada", err)
+			}
+
+			// do outro lado a conexão fecha sem entregar o que estava a caminho
+			dados, err := io.ReadAll(outra)
+			if err != nil || len(dados) > 0 {
+				t.Fatalf("o outro lado leu %q, %v; esperava só o fim da conexão", dados, err)
+			}
+		})
+	}
+}
+
+func TestRedeSimuladaCloseEntregaOQueJaFoiEscrito(t *testing.T) {
+	simulada, outra := conexaoSimuladaDeTeste(t, RedeSimulada{Latencia: 20 * time.Millisecond})
+
+	if _, err := simulada.Write([]byte("tchau")); err != nil {
+		t.Fatal(err)
+	}
+	simulada.Close()
+	if _, err := simulada.Write([]byte("depois")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("Write depois do Close = %v, esperava net.ErrClosed", err)
+	}
+
+	dados, err := io.ReadAll(outra)
+	if err != nil || string(dados) != "tchau" {
+		t.Fatalf("o outro lado leu %q, %v; esperava \"tchau\" e o fim da conexão", dados, err)
+	}
+}
+
+func TestRedeSimuladaMesmaSementeMesmasQuedas(t *testing.T) {
+	// quantas escritas passam até a queda sorteada derrubar a conexão
+	escritasAteCair := func() int {
+		simulada, outra := conexaoSimuladaDeTeste(t, RedeSimulada{ChanceQueda: 0.2, Semente: 42})
+		go io.Copy(io.Discard, outra)
+		for n := 0; ; n++ {
+			if _, err := simulada.Write([]byte("x")); err != nil {
+				return n
+			}
+		}
+	}
+
+	// a n-ésima conexão sorteia sempre a mesma coisa: recomeça a contagem pras duas rodadas
+	contagem := conexoesSimuladas.Load()
+	defer conexoesSimuladas.Store(contagem)
+
+	var rodadas [2][5]int
+	for r := range rodadas {
+		conexoesSimuladas.Store(0)
+		for i := range rodadas[r] {
+			rodadas[r][i] = escritasAteCair()
+		}
+	}
+	if rodadas[0] != rodadas[1] {
+		t.Fatalf("a mesma semente derrubou em escritas diferentes: %v e %v", rodadas[0], rodadas[1])
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Renderizador recebe do GameClient cada estado novo do jogo pra mostrar (ou não) em algum lugar
+type Renderizador interface {
+	Desenhar(estado *EstadoJogo)
+}
+
+// desenha o jogo no terminal usando termbox
+type RenderizadorTermbox struct {
+	mutex  sync.Mutex // termbox não é seguro pra desenhar de várias goroutines ao mesmo tempo
+	painel bool       // um painel (estatísticas, comando, reconexão) está cobrindo o jogo
+	ultimo *EstadoJogo
+}
+
+// Inicia o terminal e devolve o renderizador; chame Fechar no final
+func NovoRenderizadorTermbox() (*RenderizadorTermbox, error) {
+	if err := termbox.Init(); err != nil {
+		return nil, err
+	}
+	return &RenderizadorTermbox{}, nil
+}
+
+func (r *RenderizadorTermbox) Desenhar(estado *EstadoJogo) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.ultimo = estado
+	if !r.painel {
+		DesenharEstadoJogo(estado)
+	}
+}
+
+// Cobre o jogo com um painel de texto até FecharPainel
+func (r *RenderizadorTermbox) MostrarPainel(titulo string, linhas []string, rodape string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.painel = true
+	DesenharPainel(titulo, linhas, rodape)
+}
+
+// Volta a mostrar o jogo
+func (r *RenderizadorTermbox) FecharPainel() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.painel = false
+	if r.ultimo != nil {
+		DesenharEstadoJogo(r.ultimo)
+	}
+}
+
+// Mostra a tela de reconexão
+func (r *RenderizadorTermbox) DesenharLobby(titulo, instrucao string) {
+	r.MostrarPainel(titulo, nil, instrucao)
+}
+
+// Restaura o terminal
+func (r *RenderizadorTermbox) Fechar() {
+	FinalizarInterface()
+}
+
+// ignora todos os estados (bots, testes, ferramentas sem tela)
+type RenderizadorNulo struct{}
+
+func (RenderizadorNulo) Desenhar(*EstadoJogo) {}
+
+// guarda os estados recebidos pra serem inspecionados depois
+type RenderizadorGravador struct {
+	mutex   sync.Mutex
+	estados []*EstadoJogo
+	limite  int // quantos estados manter (0 = todos)
+}
+
+// Cria um gravador que mantém no máximo os últimos `limite` estados (0 = sem limite)
+func NovoRenderizadorGravador(limite int) *RenderizadorGravador {
+	return &RenderizadorGravador{limite: limite}
+}
+
+func (r *RenderizadorGravador) Desenhar(estado *EstadoJogo) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.estados = append(r.estados, estado)
+	if r.limite > 0 && len(r.estados) > r.limite {
+		r.estados = r.estados[len(r.estados)-r.limite:]
+	}
+}
+
+// Cópia da lista de estados gravados, do mais antigo pro mais novo
+func (r *RenderizadorGravador) Estados() []*EstadoJogo {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*EstadoJogo(nil), r.estados...)
+}
+
+// Último estado recebido (nil se nada foi gravado ainda)
+func (r *RenderizadorGravador) Ultimo() *EstadoJogo {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.estados) == 0 {
+		return nil
+	}
+	return r.estados[len(r.estados)-1]
+}
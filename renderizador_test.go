@@ -0,0 +1,116 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// o quadro que o estado mostraria: o mapa com os jogadores conectados por cima
+func quadroTexto(estado *EstadoJogo) []string {
+	grade := make([][]rune, len(estado.Mapa))
+	for y, linha := range estado.Mapa {
+		for _, elem := range linha {
+			grade[y] = append(grade[y], elem.Simbolo)
+		}
+	}
+	for _, jogador := range estado.Jogadores {
+		if jogador.Conectado && jogador.PosY >= 0 && jogador.PosY < len(grade) && jogador.PosX >= 0 && jogador.PosX < len(grade[jogador.PosY]) {
+			grade[jogador.PosY][jogador.PosX] = jogador.Simbolo
+		}
+	}
+	quadro := make([]string, len(grade))
+	for y, linha := range grade {
+		quadro[y] = string(linha)
+	}
+	return quadro
+}
+
+// cliente sem conexão, com o mapa carregado e o jogador local em (1, 1)
+func clienteDeTeste(t *testing.T, renderizador Renderizador) *GameClient {
+	t.Helper()
+	gc := &GameClient{gameManager: NewGameManager(), renderizador: renderizador, jogadorID: "local"}
+	err := gc.gameManager.InicializarJogoComLinhas([]string{
+		"▤▤▤▤▤▤",
+		"▤  ♣ ▤",
+		"▤▤▤▤▤▤",
+	})
+	if err != nil {
+		t.Fatalf("erro ao carregar o mapa: %v", err)
+	}
+	gc.gameManager.CriarJogadorLocal("local", "Ana", 1, 1, CorPadrao)
+	return gc
+}
+
+func TestRenderizadorGravadorDesenhaOEstado(t *testing.T) {
+	gravador := NovoRenderizadorGravador(0)
+	gc := clienteDeTeste(t, gravador)
+
+	gc.gameManager.AtualizarJogadoresRemotos(map[string]PosicaoJogador{
+		"local":  {ID: "local", PosX: 1, PosY: 1, Conectado: true},
+		"remoto": {ID: "remoto", Nome: "Bia", PosX: 4, PosY: 1, Simbolo: '☻', Conectado: true},
+	})
+	gc.notificar()
+	if _, err := gc.gameManager.MoverJogadorLocal('d'); err != nil {
+		t.Fatalf("erro ao mover: %v", err)
+	}
+	gc.notificar()
+
+	estados := gravador.Estados()
+	if len(estados) != 2 {
+		t.Fatalf("esperava 2 estados gravados, veio %d", len(estados))
+	}
+	if gravador.Ultimo() != estados[1] {
+		t.Fatalf("Ultimo() não é o último estado gravado")
+	}
+
+	quadros := [][]string{
+		{
+			"▤▤▤▤▤▤",
+			"▤☺ ♣☻▤",
+			"▤▤▤▤▤▤",
+		},
+		{
+			"▤▤▤▤▤▤",
+			"▤ ☺♣☻▤",
+			"▤▤▤▤▤▤",
+		},
+	}
+	for i, esperado := range quadros {
+		if quadro := quadroTexto(estados[i]); !slices.Equal(quadro, esperado) {
+			t.Errorf("quadro %d:\n%q\nesperava:\n%q", i, quadro, esperado)
+		}
+	}
+	if status := gravador.Ultimo().StatusMsg; status != "Você moveu para (2, 1)" {
+		t.Errorf("StatusMsg = %q", status)
+	}
+}
+
+func TestRenderizadorGravadorSemJogadorLocal(t *testing.T) {
+	gravador := NovoRenderizadorGravador(0)
+	gc := clienteDeTeste(t, gravador)
+	gc.jogadorID = "outro" // o jogador local não está no jogo: nada é desenhado
+
+	gc.notificar()
+	if gravador.Ultimo() != nil {
+		t.Fatalf("não esperava nenhum estado gravado, veio %+v", gravador.Ultimo())
+	}
+}
+
+func TestRenderizadorGravadorLimite(t *testing.T) {
+	gravador := NovoRenderizadorGravador(2)
+	desenhados := []*EstadoJogo{{StatusMsg: "1"}, {StatusMsg: "2"}, {StatusMsg: "3"}}
+	for _, estado := range desenhados {
+		gravador.Desenhar(estado)
+	}
+
+	estados := gravador.Estados()
+	if !slices.Equal(estados, desenhados[1:]) {
+		t.Fatalf("esperava só os 2 últimos estados, veio %d", len(estados))
+	}
+
+	// a lista devolvida é uma cópia
+	estados[0] = nil
+	if gravador.Estados()[0] != desenhados[1] {
+		t.Fatalf("mexer na lista devolvida mudou o gravador")
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// tipos de evento do arquivo de replay
+const (
+	EventoInicio       = "inicio"       // primeira linha: mapa inicial e taxa de tick
+	EventoConectar     = "conectar"     // jogador entrou
+	EventoMover        = "mover"        // movimento aceito, com a posição resultante
+	EventoDesconectar  = "desconectar"  // jogador saiu (desconectou, caiu, foi expulso)
+	EventoReposicionar = "reposicionar" // servidor moveu o jogador (teleporte, corrida, mapa novo)
+	EventoMapa         = "mapa"         // troca de mapa
+	EventoAviso        = "aviso"        // aviso mostrado a todos
+	EventoChat         = "chat"         // mensagem de chat de um jogador
+)
+
+// uma linha do arquivo de replay (JSON lines)
+type EventoReplay struct {
+	Tipo     string   `json:"tipo"`
+	Tick     int64    `json:"tick"`
+	EmMs     int64    `json:"em_ms"` // milissegundos desde o começo da gravação
+	Jogador  string   `json:"jogador,omitempty"`
+	Nome     string   `json:"nome,omitempty"`
+	Seq      int64    `json:"seq,omitempty"`
+	Tecla    string   `json:"tecla,omitempty"`
+	X        int      `json:"x,omitempty"`
+	Y        int      `json:"y,omitempty"`
+	Cor      CorRede  `json:"cor,omitempty"`
+	Texto    string   `json:"texto,omitempty"`
+	Mapa     []string `json:"mapa,omitempty"`
+	TaxaTick int      `json:"taxa_tick,omitempty"`
+}
+
+// grava os eventos aceitos pelo servidor (só usado de dentro do loop da simulação)
+type GravadorPartida struct {
+	arquivo *os.File
+	saida   *bufio.Writer
+	enc     *json.Encoder
+	inicio  time.Time
+	falhou  bool
+}
+
+// Cria o arquivo de replay e grava o cabeçalho com o mapa inicial
+func NovoGravadorPartida(nome string, mapa []string, taxaTick int) (*GravadorPartida, error) {
+	arquivo, err := os.Create(nome)
+	if err != nil {
+		return nil, err
+	}
+	saida := bufio.NewWriter(arquivo)
+	g := &GravadorPartida{arquivo: arquivo, saida: saida, enc: json.NewEncoder(saida), inicio: time.Now()}
+	g.registrar(EventoReplay{Tipo: EventoInicio, Mapa: mapa, TaxaTick: taxaTick}, 0)
+	return g, g.descarregar()
+}
+
+func (g *GravadorPartida) registrar(ev EventoReplay, tick int64) {
+	if g.falhou {
+		return
+	}
+	ev.Tick = tick
+	ev.EmMs = time.Since(g.inicio).Milliseconds()
+	if err := g.enc.Encode(ev); err != nil {
+		log.Println("Erro ao gravar replay, gravação interrompida:", err)
+		g.falhou = true
+	}
+}
+
+// escreve no disco o que ficou no buffer (uma vez por tick)
+func (g *GravadorPartida) descarregar() error {
+	if g.falhou {
+		return nil
+	}
+	if err := g.saida.Flush(); err != nil {
+		log.Println("Erro ao gravar replay, gravação interrompida:", err)
+		g.falhou = true
+		return err
+	}
+	return nil
+}
+
+func (g *GravadorPartida) fechar() error {
+	g.descarregar()
+	return g.arquivo.Close()
+}
+
+// grava um evento se a gravação estiver ligada (só chamado dentro do loop)
+func (gs *GameServer) gravar(ev EventoReplay) {
+	if gs.gravador != nil {
+		gs.gravador.registrar(ev, gs.tick)
+	}
+}
+
+// mostra um aviso a todos e grava no replay (só chamado dentro do loop)
+func (gs *GameServer) anunciar(mensagem string) {
+	gs.aviso = mensagem
+	gs.avisoID++
+	gs.gravar(EventoReplay{Tipo: EventoAviso, Texto: mensagem})
+}
+
+// ---- reprodução ----
+
+// uma partida gravada, carregada inteira na memória
+type Replay struct {
+	Mapa     []string // mapa inicial (vazio = o servidor não mandava mapa)
+	TaxaTick int
+	Eventos  []EventoReplay // sem o cabeçalho, em ordem de tick
+	Ticks    int64          // tick do último evento
+}
+
+// Lê um arquivo de replay
+func CarregarReplay(nome string) (*Replay, error) {
+	arquivo, err := os.Open(nome)
+	if err != nil {
+		return nil, err
+	}
+	defer arquivo.Close()
+
+	replay := &Replay{}
+	scanner := bufio.NewScanner(arquivo)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // o cabeçalho e as trocas de mapa são linhas grandes
+	for linha := 1; scanner.Scan(); linha++ {
+		var ev EventoReplay
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("replay %s, linha %d: %w", nome, linha, err)
+		}
+		if ev.Tipo == EventoInicio {
+			replay.Mapa, replay.TaxaTick = ev.Mapa, ev.TaxaTick
+			continue
+		}
+		replay.Eventos = append(replay.Eventos, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if replay.TaxaTick <= 0 {
+		return nil, fmt.Errorf("replay %s sem cabeçalho", nome)
+	}
+	if n := len(replay.Eventos); n > 0 {
+		replay.Ticks = replay.Eventos[n-1].Tick
+	}
+	return replay, nil
+}
+
+// estado reconstruído da partida num tick
+type ReprodutorReplay struct {
+	replay    *Replay
+	mapaBase  [][]Elemento // mapa usado quando a gravação não tem um
+	mapa      [][]Elemento
+	jogadores map[string]*Jogador
+	aviso     string
+	chat      []string // últimas mensagens de chat
+	proximo   int      // índice do próximo evento a aplicar
+	tick      int64    // tick atual
+}
+
+// Cria o reprodutor no começo da partida; mapaBase é usado se o replay não trouxer mapa
+func NovoReprodutorReplay(replay *Replay, mapaBase [][]Elemento) (*ReprodutorReplay, error) {
+	r := &ReprodutorReplay{replay: replay, mapaBase: mapaBase}
+	return r, r.reiniciar()
+}
+
+// volta pro tick zero
+func (r *ReprodutorReplay) reiniciar() error {
+	r.mapa = r.mapaBase
+	if len(r.replay.Mapa) > 0 {
+		jogo := &Jogo{}
+		if err := CarregarMapaDeLinhas(r.replay.Mapa, jogo); err != nil {
+			return err
+		}
+		r.mapa = jogo.Mapa
+	}
+	r.jogadores = make(map[string]*Jogador)
+	r.aviso = ""
+	r.chat = nil
+	r.proximo = 0
+	r.tick = 0
+	return nil
+}
+
+// aplica um evento no estado
+func (r *ReprodutorReplay) aplicar(ev EventoReplay) {
+	switch ev.Tipo {
+	case EventoConectar:
+		r.jogadores[ev.Jogador] = &Jogador{ID: ev.Jogador, Nome: ev.Nome, PosX: ev.X, PosY: ev.Y, Cor: ev.Cor.Terminal(), Simbolo: '☺', Conectado: true}
+	case EventoMover, EventoReposicionar:
+		if jogador, existe := r.jogadores[ev.Jogador]; existe {
+			jogador.PosX, jogador.PosY = ev.X, ev.Y
+		}
+	case EventoDesconectar:
+		delete(r.jogadores, ev.Jogador)
+	case EventoMapa:
+		jogo := &Jogo{}
+		if err := CarregarMapaDeLinhas(ev.Mapa, jogo); err == nil {
+			r.mapa = jogo.Mapa
+		}
+	case EventoAviso:
+		r.aviso = ev.Texto
+	case EventoChat:
+		r.chat = append(r.chat[max(0, len(r.chat)-mensagensChat+1):], ev.Nome+": "+ev.Texto)
+	}
+}
+
+// Vai pro tick pedido (pra trás reconstrói do começo)
+func (r *ReprodutorReplay) IrPara(tick int64) {
+	tick = max(0, min(tick, r.replay.Ticks))
+	if tick < r.tick {
+		r.reiniciar()
+	}
+	eventos := r.replay.Eventos
+	for r.proximo < len(eventos) && eventos[r.proximo].Tick <= tick {
+		r.aplicar(eventos[r.proximo])
+		r.proximo++
+	}
+	r.tick = tick
+}
+
+// Estado pronto pra desenhar
+func (r *ReprodutorReplay) Estado(status string) *EstadoJogo {
+	jogadores := make(map[string]*Jogador, len(r.jogadores))
+	for id, jogador := range r.jogadores {
+		copia := *jogador
+		jogadores[id] = &copia
+	}
+	return &EstadoJogo{Mapa: r.mapa, Jogadores: jogadores, StatusMsg: status, Aviso: r.aviso, Chat: r.chat}
+}
+
+// velocidades de reprodução disponíveis
+var velocidadesReplay = []float64{0.25, 0.5, 1, 2, 4, 8, 16}
+
+// Reproduz um replay no terminal: espaço pausa, +/- muda a velocidade, setas pulam 5s, ESC sai
+func runReplay(arquivo, mapaFile string) {
+	replay, err := CarregarReplay(arquivo)
+	if err != nil {
+		log.Fatal("Erro ao abrir replay:", err)
+	}
+
+	// gravações de servidores sem mapa usam o mapa local, como os clientes faziam
+	var mapaBase [][]Elemento
+	if len(replay.Mapa) == 0 {
+		jogo := &Jogo{}
+		if err := CarregarMapa(mapaFile, jogo); err != nil {
+			log.Fatal("Replay sem mapa e erro ao carregar o mapa local:", err)
+		}
+		mapaBase = jogo.Mapa
+	}
+	reprodutor, err := NovoReprodutorReplay(replay, mapaBase)
+	if err != nil {
+		log.Fatal("Erro no mapa do replay:", err)
+	}
+
+	IniciarInterface()
+	defer FinalizarInterface()
+
+	eventos := make(chan termbox.Event)
+	go func() {
+		for {
+			eventos <- termbox.PollEvent()
+		}
+	}()
+
+	quadro := time.NewTicker(50 * time.Millisecond)
+	defer quadro.Stop()
+
+	velocidade := sort.SearchFloat64s(velocidadesReplay, 1)
+	pausado := false
+	posicao := 0.0 // tick atual com fração, pra velocidades menores que 1
+	salto := float64(5 * replay.TaxaTick)
+	for {
+		select {
+		case ev := <-eventos:
+			if ev.Type != termbox.EventKey {
+				break
+			}
+			switch {
+			case ev.Key == termbox.KeyEsc || ev.Ch == 'q':
+				return
+			case ev.Key == termbox.KeySpace:
+				pausado = !pausado
+			case ev.Ch == '+' || ev.Ch == '=':
+				velocidade = min(velocidade+1, len(velocidadesReplay)-1)
+			case ev.Ch == '-':
+				velocidade = max(velocidade-1, 0)
+			case ev.Key == termbox.KeyArrowRight:
+				posicao += salto
+			case ev.Key == termbox.KeyArrowLeft:
+				posicao -= salto
+			case ev.Key == termbox.KeyHome || ev.Ch == '0':
+				posicao = 0
+			}
+		case <-quadro.C:
+			if !pausado {
+				posicao += velocidadesReplay[velocidade] * float64(replay.TaxaTick) * 0.05
+			}
+		}
+
+		posicao = max(0, min(posicao, float64(replay.Ticks)))
+		reprodutor.IrPara(int64(posicao))
+
+		estado := "▶"
+		if pausado {
+			estado = "❚❚"
+		}
+		segundos := func(tick int64) float64 { return float64(tick) / float64(replay.TaxaTick) }
+		status := fmt.Sprintf("Replay %s %.1fs/%.1fs (tick %d) %gx | espaço pausa, +/- velocidade, ←/→ 5s, 0 início, ESC sai",
+			estado, segundos(reprodutor.tick), segundos(replay.Ticks), reprodutor.tick, velocidadesReplay[velocidade])
+		DesenharEstadoJogo(reprodutor.Estado(status))
+	}
+}
@@ -1,181 +1,473 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/rpc"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-// Servidor que gerencia apenas as posições dos jogadores
+// tamanho das filas de comandos e entradas esperando o loop da simulação
+const tamanhoFilaComandos = 1024
+
+var ErrMapaCheio = errors.New("não tem lugar livre no mapa")
+
+// Servidor que gerencia apenas as posições dos jogadores.
+//
+// O estado mutável pertence à goroutine do loop da simulação (loopSimulacao),
+// que roda num ritmo fixo de ticks. As RPCs só enfileiram comandos e entradas;
+// a cada tick o loop aplica tudo, avança o mundo e publica um snapshot imutável
+// num ponteiro atômico, então as leituras nunca travam.
 type GameServer struct {
-	jogadores   map[string]PosicaoJogador // mapa com todas as posições dos jogadores
-	processados map[string]int64          // jogadorID -> último sequence number processado
-	mutex       sync.RWMutex              // trava de sincronização
+	config       ConfigServidor
+	jogadores    map[string]PosicaoJogador // mapa com todas as posições dos jogadores (só o loop mexe)
+	processados  map[string]int64          // jogadorID -> último sequence number processado (só o loop mexe)
+	filas        map[string][]acaoJogador  // jogadorID -> movimentos e comandos esperando a vez (só o loop mexe)
+	mapa         []string                  // linhas do mapa enviado aos clientes (vazio = cada cliente usa o seu)
+	spawns       []Ponto                   // pontos de nascimento lidos do mapa
+	elementos    [][]Elemento              // mapa interpretado, usado pra validar movimentos (nil = sem mapa)
+	tick         int64                     // número do tick atual
+	versaoMapa   int64                     // incrementada a cada troca de mapa
+	aviso        string                    // mensagem da administração mostrada a todos
+	avisoID      int64                     // incrementado a cada aviso
+	comandos     chan comandoServidor      // alterações esperando o próximo tick
+	entradas     chan acaoJogador          // movimentos e comandos recebidos esperando o próximo tick
+	respostas    []respostaAcao            // resultados a entregar depois da publicação (só o loop mexe)
+	chat         []MensagemChat            // últimas mensagens de chat (só o loop mexe)
+	chatID       int64                     // id da última mensagem de chat
+	emotes       map[string]int64          // jogadorID -> tick em que o emote some (só o loop mexe)
+	atividade    sync.Map                  // jogadorID -> horário (UnixNano) da última RPC
+	tentativas   *limitadorTentativas      // senhas erradas por ip
+	inicio       time.Time                 // quando o servidor foi criado
+	conexoes     sync.Map                  // *conexaoCliente -> struct{}: conexões abertas (pra expulsar)
+	banidos      *listaBanidos             // nomes e ips que não podem entrar
+	salvos       map[string]JogadorSalvo   // perfil -> onde o jogador estava quando saiu (só o loop mexe)
+	perfis       *ArmazemPerfis            // perfis e estatísticas (nil = desligado)
+	sessoes      map[string]sessaoPerfil   // jogadorID -> perfil em jogo (só o loop mexe)
+	gravador     *GravadorPartida          // replay da partida (nil = sem gravação; só o loop mexe)
+	espectadores sync.Map                  // id -> nome de quem só está assistindo
+
+	// desligamento
+	mutexRede          sync.Mutex     // protege listeners e fechado
+	listeners          []net.Listener // guardados pra parar de aceitar conexões (jogo, JSON e web)
+	fechado            bool           // o servidor começou a desligar e não aceita mais conexões
+	emAndamento        atomic.Int64   // RPCs lidas e ainda sem resposta
+	atendendo          sync.WaitGroup // conexões sendo atendidas
+	desligar           sync.Once
+	parar              chan struct{} // fechado pra parar o loop da simulação
+	parado             chan struct{} // fechado quando o loop parou
+	desligado          chan struct{} // fechado quando o desligamento terminou
+	motivoDesligamento string        // vazio = funcionando normalmente (só o loop mexe)
+	desligarEm         time.Time     // quando as conexões vão ser fechadas (só o loop mexe)
+	snapshot           atomic.Pointer[snapshotServidor]
+}
+
+// foto imutável do estado, compartilhada entre todas as leituras
+type snapshotServidor struct {
+	jogadores    map[string]PosicaoJogador // só jogadores conectados; nunca é alterado depois de publicado
+	processados  map[string]int64
+	tick         int64    // tick em que a foto foi tirada
+	mapa         []string // linhas do mapa atual (nunca alteradas depois de publicadas)
+	versaoMapa   int64
+	aviso        string
+	avisoID      int64
+	chat         []MensagemChat // nunca alterado depois de publicado
+	desligamento string         // motivo do desligamento em andamento (vazio = nenhum)
+	desligarEm   time.Time
 }
 
-// Serviço RPC para comunicação com clientes
+// alteração que roda na goroutine do loop da simulação
+type comandoServidor struct {
+	executar func()
+	feito    chan struct{} // fechado depois que o snapshot com a alteração foi publicado
+}
+
+// Serviço RPC para comunicação com clientes (um por conexão)
 type GameService struct {
-	servidor *GameServer // referência ao servidor de posições
+	servidor *GameServer     // referência ao servidor de posições
+	conexao  *conexaoCliente // conexão atendida por este serviço
 }
 
 // Cria um novo servidor de posições de jogadores
 func NewGameServer() *GameServer {
-	return &GameServer{
+	gs, _ := NewGameServerWithConfig(ConfigServidorPadrao)
+	return gs
+}
+
+// Cria um servidor que distribui o mapa informado para todos os clientes
+func NewGameServerComMapa(linhas []string) (*GameServer, error) {
+	cfg := ConfigServidorPadrao
+	cfg.Mapa = linhas
+	return NewGameServerWithConfig(cfg)
+}
+
+// Cria um servidor com uma config específica e começa a simulação
+func NewGameServerWithConfig(cfg ConfigServidor) (*GameServer, error) {
+	if cfg.TaxaTick <= 0 || cfg.MovimentosPorTick <= 0 || cfg.TamanhoFila <= 0 || cfg.MaxTentativasSenha <= 0 {
+		return nil, fmt.Errorf("config do servidor inválida: taxa de tick, movimentos por tick, fila e tentativas de senha precisam ser positivos")
+	}
+
+	gs := &GameServer{
+		config:      cfg,
 		jogadores:   make(map[string]PosicaoJogador),
 		processados: make(map[string]int64),
+		filas:       make(map[string][]acaoJogador),
+		emotes:      make(map[string]int64),
+		mapa:        cfg.Mapa,
+		comandos:    make(chan comandoServidor, tamanhoFilaComandos),
+		entradas:    make(chan acaoJogador, tamanhoFilaComandos),
+		tentativas:  novoLimitadorTentativas(cfg.MaxTentativasSenha, cfg.BloqueioSenha),
+		inicio:      time.Now(),
+		banidos:     novaListaBanidos(),
+		salvos:      make(map[string]JogadorSalvo),
+		sessoes:     make(map[string]sessaoPerfil),
+		parar:       make(chan struct{}),
+		parado:      make(chan struct{}),
+		desligado:   make(chan struct{}),
+	}
+
+	if len(cfg.Mapa) > 0 {
+		jogo := &Jogo{}
+		if err := CarregarMapaDeLinhas(cfg.Mapa, jogo); err != nil {
+			return nil, err
+		}
+		gs.spawns = jogo.Spawns
+		gs.elementos = jogo.Mapa
+	}
+
+	if (cfg.ArquivoEstado != "" || cfg.ArquivoPerfis != "") && cfg.IntervaloSalvamento <= 0 {
+		return nil, fmt.Errorf("config do servidor inválida: intervalo de salvamento precisa ser positivo")
+	}
+
+	// Estado salvo de uma execução anterior (o mapa salvo vale mais que o da config)
+	if cfg.ArquivoEstado != "" {
+		estado, err := CarregarEstadoArquivo(cfg.ArquivoEstado)
+		if err != nil {
+			return nil, err
+		}
+		if estado != nil {
+			if err := gs.restaurarEstado(estado); err != nil {
+				return nil, err
+			}
+			log.Printf("Estado restaurado de %s (salvo em %s, %d jogadores)",
+				cfg.ArquivoEstado, estado.SalvoEm.Format(time.DateTime), len(estado.Jogadores))
+		}
+	}
+
+	// Perfis dos jogadores
+	if cfg.ArquivoPerfis != "" {
+		var err error
+		if gs.perfis, err = AbrirArmazemPerfis(cfg.ArquivoPerfis); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ArquivoEstado != "" && cfg.ArquivoPerfis == "" {
+		log.Println("Sem -perfis, a posição dos jogadores não é salva: só quem entra com segredo volta pra onde estava")
 	}
+	if cfg.ArquivoEstado != "" || cfg.ArquivoPerfis != "" {
+		go gs.loopPersistencia()
+	}
+
+	// Gravação da partida, começando do mapa atual
+	if cfg.ArquivoGravacao != "" {
+		var err error
+		if gs.gravador, err = NovoGravadorPartida(cfg.ArquivoGravacao, gs.mapa, cfg.TaxaTick); err != nil {
+			return nil, fmt.Errorf("erro ao criar gravação: %w", err)
+		}
+		log.Println("Gravando a partida em", cfg.ArquivoGravacao)
+	}
+
+	gs.publicar()
+	go gs.loopSimulacao()
+	return gs, nil
+}
+
+// roda a alteração no próximo tick e espera o snapshot com ela ser publicado.
+// Com o loop parado (servidor desligado) a alteração pode não rodar.
+func (gs *GameServer) executar(f func()) {
+	cmd := comandoServidor{executar: f, feito: make(chan struct{})}
+	select {
+	case gs.comandos <- cmd:
+	case <-gs.parado:
+		return
+	}
+	select {
+	case <-cmd.feito:
+	case <-gs.parado:
+	}
+}
+
+// monta a resposta de posições a partir do snapshot mais recente
+func (gs *GameServer) posicoesPara(jogadorID string) PosicoesJogadores {
+	snap := gs.snapshot.Load()
+	var desligaEm time.Duration
+	if snap.desligamento != "" {
+		desligaEm = max(0, time.Until(snap.desligarEm))
+	}
+	return PosicoesJogadores{
+		Jogadores:        snap.jogadores,
+		JogadorID:        jogadorID,
+		UltimoProcessado: snap.processados[jogadorID],
+		Tick:             snap.tick,
+		Aviso:            snap.aviso,
+		AvisoID:          snap.avisoID,
+		VersaoMapa:       snap.versaoMapa,
+		Desligamento:     snap.desligamento,
+		DesligaEm:        desligaEm,
+		Chat:             snap.chat,
+	}
+}
+
+// anota que o jogador fez alguma RPC (usado pra remover quem sumiu sem desconectar)
+func (gs *GameServer) registrarAtividade(jogadorID string) {
+	gs.atividade.Store(jogadorID, time.Now().UnixNano())
 }
 
 // Inicia o servidor RPC na porta especificada
 func (gs *GameServer) StartRPC(port string) error {
-	service := &GameService{servidor: gs} // cria o serviço RPC
-	rpc.Register(service)                 // registra o serviço
+	return gs.StartRPCWithConfig(NewConfig("", port, ""))
+}
 
-	// Inicia o listener TCP na porta especificada
-	listener, err := net.Listen("tcp", ":"+port)
+// Inicia o servidor RPC com uma config de rede específica (porta e TLS)
+func (gs *GameServer) StartRPCWithConfig(config NetworkConfig) error {
+	// Inicia o listener na porta especificada (TLS se houver certificado)
+	listener, err := gs.escutar(config)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Servidor RPC de posições iniciado na porta %s", port)
+	if config.CertFile != "" {
+		log.Printf("Servidor RPC de posições iniciado na porta %s (TLS)", config.Port)
+	} else {
+		log.Printf("Servidor RPC de posições iniciado na porta %s", config.Port)
+	}
 
 	// Loop para aceitar conexões
 	for {
 		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return ErrServidorDesligado // o desligamento fechou o listener
+		}
 		if err != nil {
 			log.Printf("Erro ao aceitar conexão: %v", err)
 			continue
 		}
-		go rpc.ServeConn(conn) // atende cada conexão em paralelo
+		gs.atendendo.Add(1)
+		go gs.atenderConexao(config.Rede.Embrulhar(conn)) // atende cada conexão em paralelo
 	}
 }
 
-// RPC: Jogador se conecta ao servidor de posições
-func (gs *GameService) ConectarJogo(req ConectarRequest, reply *ConectarPosicaoResponse) error {
-	gs.servidor.mutex.Lock()
-	defer gs.servidor.mutex.Unlock()
+// abre a porta da config e guarda o listener pro desligamento (que pode ter começado antes)
+func (gs *GameServer) escutar(config NetworkConfig) (net.Listener, error) {
+	listener, err := config.Listen()
+	if err != nil {
+		return nil, err
+	}
 
-	// Cria um novo ID para o jogador
-	jogadorID := uuid.New().String()
+	gs.mutexRede.Lock()
+	defer gs.mutexRede.Unlock()
+	if gs.fechado {
+		listener.Close()
+		return nil, ErrServidorDesligado
+	}
+	gs.listeners = append(gs.listeners, listener)
+	return listener, nil
+}
+
+// Atende uma conexão com um serviço próprio, pra saber quais jogadores ela criou.
+// Quando a conexão fecha, os jogadores dela (e só eles) saem do jogo.
+func (gs *GameServer) atenderConexao(conn net.Conn) {
+	gs.atenderConexaoCom(novaConexaoCliente(conn), novoCodecServidor(conn, &gs.emAndamento)) // conta as RPCs em andamento pro desligamento
+}
 
-	// Determina a cor do jogador baseado na quantidade atual de jogadores
-	corIndex := len(gs.servidor.jogadores) % len(CoresJogadores)
+// Atende uma conexão já embrulhada num codec (gob, JSON, WebSocket)
+func (gs *GameServer) atenderConexaoCom(conexao *conexaoCliente, codec rpc.ServerCodec) {
+	defer gs.atendendo.Done()
+	gs.conexoes.Store(conexao, struct{}{})
+	defer gs.conexoes.Delete(conexao)
 
-	// Encontra uma posição inicial (simplificada)
-	posX, posY := 5+len(gs.servidor.jogadores)*2, 5+len(gs.servidor.jogadores)*2
+	servidorRPC := rpc.NewServer()
+	servidorRPC.Register(&GameService{servidor: gs, conexao: conexao})
+	servidorRPC.ServeCodec(codec)
 
-	// Cria novo jogador com as informações básicas
-	novoJogador := PosicaoJogador{
-		ID:        jogadorID,
-		Nome:      req.Nome,
-		PosX:      posX,
-		PosY:      posY,
-		Cor:       CoresJogadores[corIndex],
-		Simbolo:   '☺',
-		Conectado: true,
+	ids := conexao.idsJogadores()
+	for _, id := range ids {
+		gs.removerEspectador(id)
+	}
+	if len(ids) == 0 {
+		return
 	}
+	gs.executar(func() {
+		for _, id := range ids {
+			if jogador, existe := gs.jogadores[id]; existe {
+				log.Printf("Jogador %s saiu (conexão %s fechada)", jogador.Nome, conexao.endereco)
+				gs.removerJogador(id)
+			}
+		}
+	})
+}
 
-	// Adiciona o jogador ao mapa de posições
-	gs.servidor.jogadores[jogadorID] = novoJogador
-	gs.servidor.processados[jogadorID] = 0
+// RPC: Jogador se conecta ao servidor de posições
+func (gs *GameService) ConectarJogo(req ConectarRequest, reply *ConectarPosicaoResponse) error {
+	// Só entra quem combinou a versão do protocolo
+	if err := gs.exigirHandshake(); err != nil {
+		return err
+	}
 
-	// Prepara a resposta para o cliente
-	posicoes := PosicoesJogadores{
-		Jogadores:        gs.servidor.copiarPosicoes(),
-		JogadorID:        jogadorID,
-		UltimoProcessado: 0,
+	// Servidor com senha: confere antes de qualquer coisa
+	if gs.servidor.config.Senha != "" {
+		if err := gs.conferirSenha(gs.servidor.config.Senha, req.Senha, ErrSenhaIncorreta, "do servidor"); err != nil {
+			return err
+		}
 	}
 
-	reply.JogadorID = jogadorID
-	reply.Posicoes = posicoes
+	// Servidor desligando não recebe ninguém novo
+	if gs.servidor.snapshot.Load().desligamento != "" {
+		return ErrServidorDesligando
+	}
 
-	log.Printf("Jogador %s conectado (%s)", novoJogador.Nome, jogadorID)
-	return nil
-}
+	// Nome ou ip banidos pela administração não entram
+	if gs.servidor.banidos.contem(req.Nome) || gs.servidor.banidos.contem(ipDoEndereco(gs.conexao.endereco)) {
+		return ErrBanido
+	}
 
-// RPC: Jogador tenta se mover e recebe posições atualizadas
-func (gs *GameService) Mover(req MoverRequest, reply *PosicoesJogadores) error {
-	gs.servidor.mutex.Lock()
-	defer gs.servidor.mutex.Unlock()
+	// Espectador não entra no mundo: só recebe as atualizações
+	if req.Espectador {
+		return gs.conectarEspectador(req, reply)
+	}
 
-	// Verifica se esse comando já foi processado
-	if gs.servidor.processados[req.JogadorID] >= req.SequenceNumber {
-		*reply = PosicoesJogadores{
-			Jogadores:        gs.servidor.copiarPosicoes(),
-			JogadorID:        req.JogadorID,
-			UltimoProcessado: gs.servidor.processados[req.JogadorID],
+	// Com segredo, o jogador entra no perfil dele (criado na primeira vez)
+	comPerfil := req.Segredo != "" && gs.servidor.perfis != nil
+	if comPerfil {
+		if err := gs.entrarPerfil(req.Nome, req.Segredo); err != nil {
+			return err
 		}
-		return nil
 	}
 
-	// Busca o jogador que está se movendo
-	jogador, existe := gs.servidor.jogadores[req.JogadorID]
-	if !existe || !jogador.Conectado {
-		return nil
-	}
+	// Cria um novo ID para o jogador
+	jogadorID := uuid.New().String()
 
-	// Calcula direção do movimento
-	dx, dy := 0, 0
-	switch req.Tecla {
-	case 'w':
-		dy = -1
-	case 'a':
-		dx = -1
-	case 's':
-		dy = 1
-	case 'd':
-		dx = 1
-	default:
-		*reply = PosicoesJogadores{
-			Jogadores:        gs.servidor.copiarPosicoes(),
-			JogadorID:        req.JogadorID,
-			UltimoProcessado: gs.servidor.processados[req.JogadorID],
+	var novoJogador PosicaoJogador
+	errEntrada := ErrServidorDesligado // fica assim se o loop parou antes de rodar a entrada
+	gs.servidor.executar(func() {
+		errEntrada = nil
+
+		// Usa o próximo spawn livre do mapa se houver, senão uma posição simplificada;
+		// o posicaoLivre procura uma célula dentro do mapa, sem parede nem outro jogador
+		preferida := Ponto{5 + len(gs.servidor.jogadores)*2, 5 + len(gs.servidor.jogadores)*2}
+		for i := range gs.servidor.spawns {
+			spawn := gs.servidor.spawns[(len(gs.servidor.jogadores)+i)%len(gs.servidor.spawns)]
+			if gs.servidor.podeOcupar(spawn.X, spawn.Y, jogadorID) {
+				preferida = spawn
+				break
+			}
+		}
+		inicio, cabe := gs.servidor.posicaoLivre(preferida, jogadorID)
+		if !cabe {
+			errEntrada = ErrMapaCheio
+			return
+		}
+		posX, posY := inicio.X, inicio.Y
+
+		// Um perfil só pode estar em uma partida por vez
+		if comPerfil {
+			for _, sessao := range gs.servidor.sessoes {
+				if sessao.perfil == req.Nome {
+					errEntrada = ErrPerfilEmUso
+					return
+				}
+			}
+			gs.servidor.sessoes[jogadorID] = sessaoPerfil{perfil: req.Nome, entrou: time.Now()}
+			gs.servidor.perfis.Registrar(req.Nome, func(e *EstatisticasJogador) { e.Partidas++ })
 		}
-		return nil
-	}
 
-	// Calcula nova posição
-	nx, ny := jogador.PosX+dx, jogador.PosY+dy
+		// Determina a cor do jogador baseado na quantidade atual de jogadores
+		corIndex := len(gs.servidor.jogadores) % len(CoresJogadores)
 
-	// Faz o movimento
-	jogador.PosX = nx
-	jogador.PosY = ny
-	gs.servidor.jogadores[req.JogadorID] = jogador
+		// Quem já jogou aqui com o perfil volta pra onde estava, se o lugar estiver livre
+		// (sem segredo não dá pra saber se é a mesma pessoa: começa do spawn)
+		if salvo, existe := gs.servidor.salvos[req.Nome]; comPerfil && existe && gs.servidor.podeOcupar(salvo.PosX, salvo.PosY, jogadorID) {
+			posX, posY = salvo.PosX, salvo.PosY
+		}
 
-	// Atualiza o processamento
-	gs.servidor.processados[req.JogadorID] = req.SequenceNumber
+		// Cria novo jogador com as informações básicas
+		novoJogador = PosicaoJogador{
+			ID:        jogadorID,
+			Nome:      req.Nome,
+			PosX:      posX,
+			PosY:      posY,
+			Cor:       CoresJogadores[corIndex],
+			Simbolo:   '☺',
+			Conectado: true,
+		}
 
-	// Prepara a resposta com posições atualizadas
-	*reply = PosicoesJogadores{
-		Jogadores:        gs.servidor.copiarPosicoes(),
-		JogadorID:        req.JogadorID,
-		UltimoProcessado: req.SequenceNumber,
+		// Adiciona o jogador ao mapa de posições
+		gs.servidor.jogadores[jogadorID] = novoJogador
+		gs.servidor.processados[jogadorID] = 0
+		gs.servidor.registrarAtividade(jogadorID) // já aqui: a procura de inativos pode rodar neste mesmo tick
+		gs.servidor.gravar(EventoReplay{Tipo: EventoConectar, Jogador: jogadorID, Nome: req.Nome, X: posX, Y: posY, Cor: novoJogador.Cor})
+	})
+	if errEntrada != nil {
+		return errEntrada
 	}
+	token := gerarToken()
+	gs.conexao.adicionarJogador(jogadorID, token, gs.servidor.config.RajadaMovimentos)
 
+	// Prepara a resposta para o cliente
+	reply.JogadorID = jogadorID
+	reply.Token = token
+	reply.Posicoes = gs.servidor.posicoesPara(jogadorID)
+	reply.Mapa = gs.servidor.snapshot.Load().mapa
+
+	log.Printf("Jogador %s conectado (%s)", novoJogador.Nome, gs.conexao.endereco)
 	return nil
 }
 
-// RPC: Cliente solicita posições atuais de todos jogadores
-func (gs *GameService) ObterPosicoes(jogadorID string, reply *PosicoesJogadores) error {
-	gs.servidor.mutex.RLock()
-	defer gs.servidor.mutex.RUnlock()
+// RPC: Jogador tenta se mover e recebe posições atualizadas.
+// O movimento entra na fila do jogador e é aplicado no próximo tick; a resposta
+// traz o último snapshot publicado (o cliente já previu o movimento localmente).
+func (gs *GameService) Mover(req MoverRequest, reply *PosicoesJogadores) error {
+	// Só aceita jogadores desta conexão, dentro do limite de velocidade
+	if err := gs.validarMovimento(req); err != nil {
+		return err
+	}
+	gs.servidor.registrarAtividade(req.JogadorID)
+
+	// Só enfileira o que ainda não foi processado e é uma tecla de movimento
+	// (sem esperar: o resultado aparece nas próximas posições)
+	snap := gs.servidor.snapshot.Load()
+	if _, _, ok := direcaoTecla(req.Tecla); ok && snap.processados[req.JogadorID] < req.SequenceNumber {
+		if err := gs.enfileirar(gs.servidor.acaoMovimento(req)); err != nil {
+			return err // o loop parou: ninguém mais lê as entradas
+		}
+	}
 
-	// Prepara a resposta com todas as posições atuais
-	*reply = PosicoesJogadores{
-		Jogadores:        gs.servidor.copiarPosicoes(),
-		JogadorID:        jogadorID,
-		UltimoProcessado: gs.servidor.processados[jogadorID],
+	*reply = gs.servidor.posicoesPara(req.JogadorID)
+	return nil
+}
+
+// RPC: Cliente solicita posições atuais de todos jogadores
+func (gs *GameService) ObterPosicoes(req SessaoRequest, reply *PosicoesJogadores) error {
+	if err := gs.verificarSessao(req.JogadorID, req.Token, "ObterPosicoes"); err != nil {
+		return err
 	}
+	gs.servidor.registrarAtividade(req.JogadorID)
 
+	// Só lê o último snapshot publicado: nunca espera pelas escritas
+	*reply = gs.servidor.posicoesPara(req.JogadorID)
 	return nil
 }
 
-// Copia as posições dos jogadores para evitar problemas de concorrência
+// Copia as posições dos jogadores conectados (só chamado pelo dono do estado)
 func (gs *GameServer) copiarPosicoes() map[string]PosicaoJogador {
-	copia := make(map[string]PosicaoJogador)
+	copia := make(map[string]PosicaoJogador, len(gs.jogadores))
 	for id, jogador := range gs.jogadores {
 		if jogador.Conectado {
 			copia[id] = jogador
@@ -185,16 +477,24 @@ func (gs *GameServer) copiarPosicoes() map[string]PosicaoJogador {
 }
 
 // RPC: Jogador se desconecta
-func (gs *GameService) Desconectar(jogadorID string, reply *bool) error {
-	gs.servidor.mutex.Lock()
-	defer gs.servidor.mutex.Unlock()
-
-	if jogador, existe := gs.servidor.jogadores[jogadorID]; existe {
-		log.Printf("Jogador %s (%s) desconectado", jogador.Nome, jogadorID)
-		delete(gs.servidor.jogadores, jogadorID)   // remove o jogador do mapa
-		delete(gs.servidor.processados, jogadorID) // remove o processamento
+func (gs *GameService) Desconectar(req SessaoRequest, reply *bool) error {
+	// Ninguém desconecta o jogador de outra sessão
+	if err := gs.verificarSessao(req.JogadorID, req.Token, "Desconectar"); err != nil {
+		return err
+	}
+	gs.conexao.removerJogador(req.JogadorID)
+	if gs.servidor.removerEspectador(req.JogadorID) {
+		*reply = true
+		return nil
 	}
 
+	gs.servidor.executar(func() {
+		if jogador, existe := gs.servidor.jogadores[req.JogadorID]; existe {
+			log.Printf("Jogador %s desconectado", jogador.Nome)
+			gs.servidor.removerJogador(req.JogadorID)
+		}
+	})
+
 	*reply = true
 	return nil
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+// servidor com o loop rodando, desligado no fim do teste
+func servidorDeTeste(t *testing.T, cfg ConfigServidor) *GameServer {
+	t.Helper()
+	gs, err := NewGameServerWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("erro ao criar o servidor: %v", err)
+	}
+	t.Cleanup(func() { gs.Desligar("fim do teste", 0) })
+	return gs
+}
+
+// serviço de uma conexão sem rede de verdade, com o handshake já feito
+func servicoDeTeste(t *testing.T, gs *GameServer) *GameService {
+	t.Helper()
+	local, remoto := net.Pipe()
+	t.Cleanup(func() { local.Close(); remoto.Close() })
+
+	conexao := novaConexaoCliente(local)
+	gs.conexoes.Store(conexao, struct{}{})
+	t.Cleanup(func() { gs.conexoes.Delete(conexao) })
+
+	servico := &GameService{servidor: gs, conexao: conexao}
+	if err := servico.Handshake(HandshakeRequest{Versao: VersaoProtocolo, Programa: "teste"}, &HandshakeResponse{}); err != nil {
+		t.Fatalf("erro no handshake: %v", err)
+	}
+	return servico
+}
+
+// entra no jogo com o nome e devolve a sessão
+func entrarDeTeste(t *testing.T, servico *GameService, nome string) ConectarPosicaoResponse {
+	t.Helper()
+	var resp ConectarPosicaoResponse
+	if err := servico.ConectarJogo(ConectarRequest{Nome: nome}, &resp); err != nil {
+		t.Fatalf("erro ao conectar %s: %v", nome, err)
+	}
+	return resp
+}
+
+func TestConectarJogoComOLoopParado(t *testing.T) {
+	gs, err := NewGameServerWithConfig(ConfigServidorPadrao)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// o loop para entre a conferência do desligamento e a entrada do jogador
+	close(gs.parar)
+	<-gs.parado
+
+	local, remoto := net.Pipe()
+	defer remoto.Close()
+	servico := &GameService{servidor: gs, conexao: novaConexaoCliente(local)}
+	servico.conexao.definirVersao(VersaoProtocolo)
+
+	var resp ConectarPosicaoResponse
+	if err := servico.ConectarJogo(ConectarRequest{Nome: "Ana"}, &resp); !errors.Is(err, ErrServidorDesligado) {
+		t.Fatalf("ConectarJogo = %v (resposta %+v), esperava ErrServidorDesligado", err, resp)
+	}
+	if ids := servico.conexao.idsJogadores(); len(ids) != 0 {
+		t.Fatalf("a conexão ficou com uma sessão de um jogador que não entrou: %v", ids)
+	}
+}
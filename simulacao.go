@@ -0,0 +1,315 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"log"
+	"slices"
+	"time"
+)
+
+// de quantos em quantos ticks a simulação procura jogadores inativos
+const ticksEntreVerificacoes = 20
+
+// loop do jogo no servidor: um tick por vez, no ritmo configurado
+func (gs *GameServer) loopSimulacao() {
+	ticker := time.NewTicker(time.Second / time.Duration(gs.config.TaxaTick))
+	defer ticker.Stop()
+	defer close(gs.parado)
+
+	for {
+		select {
+		case <-gs.parar:
+			return // desligamento
+		case <-ticker.C:
+		}
+		gs.tick++
+
+		// 1. conexões, desconexões e outras alterações pedidas pelas RPCs
+		feitos := gs.executarComandos()
+
+		// 2. movimentos: junta as entradas novas e aplica as filas em ordem
+		gs.receberEntradas()
+		gs.aplicarEntradas()
+
+		// 3. o resto do mundo
+		gs.simular()
+
+		// 4. uma foto por tick pra quem lê
+		gs.publicar()
+		if gs.gravador != nil {
+			gs.gravador.descarregar()
+		}
+		for _, feito := range feitos {
+			close(feito)
+		}
+		gs.entregarRespostas() // quem espera um comando recebe o resultado já com a foto nova
+	}
+}
+
+// uma ação de jogador esperando a vez no loop: um movimento do Mover, um Comando
+// ou um Lote. Todas dividem o mesmo SequenceNumber, então passam pela mesma fila
+// e pela mesma regra de repetidos, na ordem em que o cliente mandou.
+type acaoJogador struct {
+	JogadorID      string
+	SequenceNumber int64 // num lote, o do primeiro comando
+	Comando        string
+	aplicar        func() (any, error)   // roda dentro do loop, na vez da ação
+	resposta       chan ResultadoComando // recebe o resultado (nil = ninguém espera, como no Mover)
+	lote           []acaoJogador         // comandos de um Lote: ocupam um lugar na fila e rodam juntos
+}
+
+// resultado esperando a publicação do tick pra ser entregue
+type respostaAcao struct {
+	canal     chan ResultadoComando
+	resultado ResultadoComando
+}
+
+// ação que aplica um movimento do Mover (ninguém espera o resultado)
+func (gs *GameServer) acaoMovimento(req MoverRequest) acaoJogador {
+	return acaoJogador{
+		JogadorID:      req.JogadorID,
+		SequenceNumber: req.SequenceNumber,
+		Comando:        ComandoMover,
+		aplicar: func() (any, error) {
+			gs.aplicarMovimento(req)
+			return nil, nil
+		},
+	}
+}
+
+// guarda o resultado da ação pra quem está esperando (só chamado dentro do loop)
+func (gs *GameServer) responder(acao acaoJogador, resposta any, err error, duplicado bool) {
+	for _, comando := range acao.lote {
+		gs.responder(comando, resposta, err, duplicado) // lote recusado inteiro: cada comando recebe o motivo
+	}
+	if acao.resposta == nil {
+		return
+	}
+	resultado := ResultadoComando{
+		SequenceNumber: acao.SequenceNumber,
+		Comando:        acao.Comando,
+		Aplicado:       err == nil && !duplicado,
+		Duplicado:      duplicado,
+	}
+	if err != nil {
+		resultado.Erro = err.Error()
+	}
+	if resposta != nil && err == nil {
+		dados, errJSON := json.Marshal(resposta)
+		if errJSON != nil {
+			log.Printf("Erro ao codificar a resposta do comando %s: %v", acao.Comando, errJSON)
+		}
+		resultado.Resposta = dados
+	}
+	gs.respostas = append(gs.respostas, respostaAcao{acao.resposta, resultado})
+}
+
+// entrega os resultados do tick (os canais têm espaço pra um resultado, então nunca trava)
+func (gs *GameServer) entregarRespostas() {
+	for _, r := range gs.respostas {
+		r.canal <- r.resultado
+	}
+	gs.respostas = gs.respostas[:0]
+}
+
+// descarta as ações pendentes do jogador avisando quem espera (só chamado dentro do loop)
+func (gs *GameServer) descartarFila(id string, motivo error) {
+	for _, acao := range gs.filas[id] {
+		gs.responder(acao, nil, motivo, false)
+	}
+	delete(gs.filas, id)
+}
+
+// roda os comandos pendentes e devolve os canais a fechar depois da publicação
+func (gs *GameServer) executarComandos() []chan struct{} {
+	var feitos []chan struct{}
+	for {
+		select {
+		case cmd := <-gs.comandos:
+			cmd.executar()
+			feitos = append(feitos, cmd.feito)
+		default:
+			return feitos
+		}
+	}
+}
+
+// move as entradas do canal pras filas de cada jogador
+func (gs *GameServer) receberEntradas() {
+	for {
+		select {
+		case acao := <-gs.entradas:
+			if _, existe := gs.jogadores[acao.JogadorID]; !existe {
+				gs.responder(acao, nil, ErrSessaoInvalida, false) // jogador saiu antes do tick
+				continue
+			}
+			fila := gs.filas[acao.JogadorID]
+			if len(fila) >= gs.config.TamanhoFila {
+				log.Printf("Fila de movimentos cheia para %s, descartando seq %d", acao.JogadorID, acao.SequenceNumber)
+				gs.responder(acao, nil, ErrFilaCheia, false)
+				continue
+			}
+			gs.filas[acao.JogadorID] = append(fila, acao)
+		default:
+			return
+		}
+	}
+}
+
+// aplica até MovimentosPorTick ações de cada jogador (movimentos e comandos), em ordem de SequenceNumber.
+// Um lote conta todos os comandos dele, mas nunca fica dividido entre dois ticks.
+func (gs *GameServer) aplicarEntradas() {
+	for id, fila := range gs.filas {
+		// os pedidos podem chegar fora de ordem por conexões diferentes
+		slices.SortStableFunc(fila, func(a, b acaoJogador) int {
+			return cmp.Compare(a.SequenceNumber, b.SequenceNumber)
+		})
+
+		aplicados := 0
+		for len(fila) > 0 && aplicados < gs.config.MovimentosPorTick {
+			acao := fila[0]
+			fila = fila[1:]
+			gs.filas[id] = fila // só o que falta, se a ação descartar a fila
+			aplicados += gs.aplicarAcao(acao)
+			if _, existe := gs.filas[id]; !existe {
+				fila = nil // a ação descartou o resto (ex: venceu a corrida e voltou pro spawn)
+			}
+		}
+
+		if len(fila) == 0 {
+			delete(gs.filas, id)
+		}
+	}
+}
+
+// aplica uma ação (ou os comandos de um lote, um atrás do outro) e devolve quantas foram aplicadas
+func (gs *GameServer) aplicarAcao(acao acaoJogador) int {
+	id := acao.JogadorID
+	if acao.lote != nil {
+		aplicados := 0
+		for i, comando := range acao.lote {
+			aplicados += gs.aplicarAcao(comando)
+			if _, existe := gs.filas[id]; !existe {
+				// o comando descartou a fila (ex: venceu a corrida): o resto do lote vai junto
+				for _, resto := range acao.lote[i+1:] {
+					gs.responder(resto, nil, ErrAcaoDescartada, false)
+				}
+				break
+			}
+		}
+		return aplicados
+	}
+
+	if acao.SequenceNumber <= gs.processados[id] {
+		gs.responder(acao, nil, nil, true) // repetido ou atrasado
+		return 0
+	}
+	resposta, err := acao.aplicar()
+	gs.processados[id] = acao.SequenceNumber // mesmo com erro: o número foi usado
+	gs.responder(acao, resposta, err, false)
+	return 1
+}
+
+// aplica um movimento já validado pela ordem de sequência
+func (gs *GameServer) aplicarMovimento(req MoverRequest) {
+	jogador, existe := gs.jogadores[req.JogadorID]
+	if !existe || !jogador.Conectado {
+		return
+	}
+
+	// movimento contra parede ou outro jogador é consumido mas não move
+	dx, dy, _ := direcaoTecla(req.Tecla)
+	moveu := gs.podeOcupar(jogador.PosX+dx, jogador.PosY+dy, req.JogadorID)
+	if moveu {
+		jogador.PosX += dx
+		jogador.PosY += dy
+		gs.jogadores[req.JogadorID] = jogador
+
+		if sessao, existe := gs.sessoes[req.JogadorID]; existe {
+			gs.perfis.Registrar(sessao.perfil, func(e *EstatisticasJogador) { e.Distancia++ })
+		}
+	}
+	gs.gravar(EventoReplay{Tipo: EventoMover, Jogador: req.JogadorID, Seq: req.SequenceNumber, Tecla: string(req.Tecla), X: jogador.PosX, Y: jogador.PosY})
+
+	// só quem acabou de entrar na célula vence (parado fora do mapa nem é olhado)
+	if celula, ok := gs.celula(jogador.PosX, jogador.PosY); moveu && ok && celula.Simbolo == Saida.Simbolo {
+		gs.venceuCorrida(req.JogadorID)
+	}
+}
+
+// elemento do mapa na posição; false fora do mapa (ou sem mapa), que pode ter linhas de tamanhos diferentes
+func (gs *GameServer) celula(x, y int) (Elemento, bool) {
+	if y < 0 || y >= len(gs.elementos) || x < 0 || x >= len(gs.elementos[y]) {
+		return Elemento{}, false
+	}
+	return gs.elementos[y][x], true
+}
+
+// avança o que não depende dos jogadores: tira os emotes vencidos e remove quem sumiu sem desconectar
+func (gs *GameServer) simular() {
+	gs.expirarEmotes()
+
+	if gs.config.TempoInatividade <= 0 || gs.tick%ticksEntreVerificacoes != 0 {
+		return
+	}
+
+	limite := time.Now().Add(-gs.config.TempoInatividade).UnixNano()
+	for id, jogador := range gs.jogadores {
+		ultima, ok := gs.atividade.Load(id)
+		if ok && ultima.(int64) >= limite {
+			continue
+		}
+		log.Printf("Jogador %s (%s) removido por inatividade", jogador.Nome, id)
+		gs.removerJogador(id)
+	}
+}
+
+// tira o jogador de todas as estruturas (só chamado dentro do loop)
+func (gs *GameServer) removerJogador(id string) {
+	if jogador, existe := gs.jogadores[id]; existe {
+		gs.lembrarJogador(id, jogador)
+		gs.gravar(EventoReplay{Tipo: EventoDesconectar, Jogador: id})
+	}
+	gs.encerrarSessaoPerfil(id)
+	delete(gs.jogadores, id)
+	delete(gs.processados, id)
+	delete(gs.emotes, id)
+	gs.descartarFila(id, ErrSessaoInvalida)
+	gs.atividade.Delete(id)
+}
+
+// copia o estado atual num snapshot novo e publica (só chamado dentro do loop)
+func (gs *GameServer) publicar() {
+	processados := make(map[string]int64, len(gs.processados))
+	for id, seq := range gs.processados {
+		processados[id] = seq
+	}
+	gs.snapshot.Store(&snapshotServidor{
+		jogadores:    gs.copiarPosicoes(),
+		processados:  processados,
+		tick:         gs.tick,
+		mapa:         gs.mapa,
+		versaoMapa:   gs.versaoMapa,
+		aviso:        gs.aviso,
+		avisoID:      gs.avisoID,
+		chat:         gs.chat,
+		desligamento: gs.motivoDesligamento,
+		desligarEm:   gs.desligarEm,
+	})
+}
+
+// deslocamento de cada tecla de movimento
+func direcaoTecla(tecla rune) (dx, dy int, ok bool) {
+	switch tecla {
+	case 'w':
+		return 0, -1, true
+	case 'a':
+		return -1, 0, true
+	case 's':
+		return 0, 1, true
+	case 'd':
+		return 1, 0, true
+	}
+	return 0, 0, false
+}
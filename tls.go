@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// abre a conexão com o servidor, com TLS se a config pedir (e a rede simulada, se configurada)
+func (nc *NetworkConfig) Dial() (net.Conn, error) {
+	conn, err := nc.discar()
+	if err != nil {
+		return nil, err
+	}
+	return nc.Rede.Embrulhar(conn), nil
+}
+
+func (nc *NetworkConfig) discar() (net.Conn, error) {
+	if !nc.TLS {
+		return net.Dial("tcp", nc.GetAddress())
+	}
+
+	tlsConfig, err := nc.configTLSCliente()
+	if err != nil {
+		return nil, err
+	}
+	return tls.Dial("tcp", nc.GetAddress(), tlsConfig)
+}
+
+// começa a escutar conexões, com TLS se houver certificado e chave
+func (nc *NetworkConfig) Listen() (net.Listener, error) {
+	if nc.CertFile == "" && nc.KeyFile == "" {
+		return net.Listen("tcp", nc.GetListenAddress())
+	}
+
+	cert, err := tls.LoadX509KeyPair(nc.CertFile, nc.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao carregar certificado TLS: %w", err)
+	}
+	return tls.Listen("tcp", nc.GetListenAddress(), &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+}
+
+// monta a verificação do certificado do servidor: fingerprint fixo, CA própria ou CAs do sistema
+func (nc *NetworkConfig) configTLSCliente() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: nc.Host, MinVersion: tls.VersionTLS12}
+
+	switch {
+	case nc.Fingerprint != "":
+		// certificado autoassinado: a cadeia não é verificada, mas o certificado
+		// precisa ser exatamente o esperado
+		esperado := normalizarFingerprint(nc.Fingerprint)
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = func(certs [][]byte, _ [][]*x509.Certificate) error {
+			if len(certs) == 0 {
+				return errors.New("servidor não apresentou certificado")
+			}
+			if recebido := FingerprintCertificado(certs[0]); recebido != esperado {
+				return fmt.Errorf("fingerprint do servidor não confere: esperado %s, recebido %s", esperado, recebido)
+			}
+			return nil
+		}
+	case nc.CAFile != "":
+		pem, err := os.ReadFile(nc.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler CA: %w", err)
+		}
+		raizes := x509.NewCertPool()
+		if !raizes.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("nenhum certificado válido em %s", nc.CAFile)
+		}
+		cfg.RootCAs = raizes
+	}
+	return cfg, nil
+}
+
+// SHA-256 do certificado em hexadecimal (o mesmo que aparece ao gerar o certificado)
+func FingerprintCertificado(der []byte) string {
+	soma := sha256.Sum256(der)
+	return hex.EncodeToString(soma[:])
+}
+
+// aceita o fingerprint com ou sem ':' e em maiúsculas
+func normalizarFingerprint(f string) string {
+	return strings.ToLower(strings.ReplaceAll(f, ":", ""))
+}
+
+// GerarCertificado cria um certificado autoassinado (ECDSA P-256) pros hosts
+// informados e salva certificado e chave em PEM. Devolve o fingerprint.
+func GerarCertificado(certFile, keyFile string, hosts []string, validade time.Duration) (string, error) {
+	chave, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", err
+	}
+
+	modelo := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0], Organization: []string{"Jogo multiplayer (autoassinado)"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(validade),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true, // pra poder ser usado direto como CA no cliente (-ca)
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			modelo.IPAddresses = append(modelo.IPAddresses, ip)
+		} else {
+			modelo.DNSNames = append(modelo.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &modelo, &modelo, &chave.PublicKey, chave)
+	if err != nil {
+		return "", err
+	}
+	chaveDER, err := x509.MarshalECPrivateKey(chave)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: chaveDER}), 0600); err != nil {
+		return "", err
+	}
+	return FingerprintCertificado(der), nil
+}
@@ -35,31 +35,18 @@ type Jogador struct {
 	Cor       Cor    // cor do jogador
 	Simbolo   rune   // símbolo que representa o jogador
 	Conectado bool   // se está conectado ou não
+	Emote     rune   // símbolo do emote mostrado em cima do jogador (0 = nenhum)
 }
 
 // estrutura com o estado atual do jogo que é compartilhado com os clientes
 type EstadoJogo struct {
-	Mapa      [][]Elemento        // o mapa atual com todos os elementos
-	Jogadores map[string]*Jogador // todos os jogadores conectados
-	StatusMsg string              // mensagem de status que aparece na tela
-}
-
-// Nova estrutura para armazenar apenas as posições dos jogadores
-type PosicoesJogadores struct {
-	Jogadores        map[string]PosicaoJogador // posições de todos os jogadores
-	JogadorID        string                    // id do jogador atual
-	UltimoProcessado int64                     // último comando processado
-}
-
-// Estrutura minimalista para representar a posição de um jogador
-type PosicaoJogador struct {
-	ID        string // id único do jogador
-	Nome      string // nome do jogador
-	PosX      int    // posição x no mapa
-	PosY      int    // posição y no mapa
-	Cor       Cor    // cor do jogador
-	Simbolo   rune   // símbolo que representa o jogador
-	Conectado bool   // se está conectado ou não
+	Mapa       [][]Elemento        // o mapa atual com todos os elementos
+	Jogadores  map[string]*Jogador // todos os jogadores conectados
+	StatusMsg  string              // mensagem de status que aparece na tela
+	Aviso      string              // último aviso mandado pela administração do servidor
+	Camera     *Ponto              // centro da visão quando ela segue alguém (nil = mapa inteiro)
+	Instrucoes string              // instruções no rodapé (vazio = as do jogador)
+	Chat       []string            // últimas mensagens de chat, já com o nome de quem mandou
 }
 
 // estrutura que representa o jogo no servidor
@@ -69,6 +56,15 @@ type Jogo struct {
 	Jogadores      map[string]*Jogador
 	UltimoVisitado Elemento // guarda o último elemento que o jogador pisou
 	StatusMsg      string
+	Aviso          string   // aviso da administração do servidor
+	Spawns         []Ponto  // pontos de nascimento marcados no mapa com '☺'
+	Chat           []string // últimas mensagens de chat recebidas do servidor
+}
+
+// uma coordenada (x, y) no mapa
+type Ponto struct {
+	X int
+	Y int
 }
 
 type EventoTeclado struct {
@@ -76,49 +72,14 @@ type EventoTeclado struct {
 	Tecla rune   // tecla apertada
 }
 
-// mandar comandos do cliente pro servidor
-type ComandoRequest struct {
-	JogadorID      string
-	SequenceNumber int64       // número da ação pra evitar fora de ordem
-	Comando        string      // tipo de comando
-	Dados          interface{} // dados do comando (pode ser qualquer coisa)
-}
-
-// estrutura usada quando o jogador quer se mover
-type MoverRequest struct {
-	JogadorID      string
-	SequenceNumber int64
-	Tecla          rune
-}
-
-// estrutura usada quando o jogador se conecta
-type ConectarRequest struct {
-	MapaFile string // arquivo do mapa que o cliente quer usar
-	Nome     string // nome do jogador que está se conectando
-}
-
-// resposta do servidor quando o jogador se conecta
-type ConectarResponse struct {
-	JogadorID string     // id que o servidor gerou pro jogador
-	Estado    EstadoJogo // estado atual do jogo que o cliente vai receber
-}
-
-// Nova estrutura para resposta do servidor com apenas as posições
-type ConectarPosicaoResponse struct {
-	JogadorID string           // id que o servidor gerou pro jogador
-	Posicoes  PosicoesJogadores // posições dos jogadores
-}
-
 var (
 	Personagem = Elemento{'☺', CorBranco, CorPadrao, true}      // jogador
 	Inimigo    = Elemento{'☠', CorVermelho, CorPadrao, true}    // inimigo
 	Parede     = Elemento{'▤', CorParede, CorFundoParede, true} // parede
 	Vegetacao  = Elemento{'♣', CorVerde, CorPadrao, false}      // vegetação (não colide)
 	Vazio      = Elemento{' ', CorPadrao, CorPadrao, false}     // espaço vazio
+	Saida      = Elemento{'⚑', CorAmarelo, CorPadrao, false}    // saída do nível
 )
 
-// cores que os jogadores podem ter
-var CoresJogadores = []Cor{
-	CorBranco, CorVermelho, CorVerde, CorAzul,
-	CorAmarelo, CorMagenta, CorCyan,
-}
+// símbolo que marca um ponto de nascimento no arquivo de mapa
+const SimboloSpawn = '☺'
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// intervalo entre os passos da caminhada automática
+const intervaloViagem = 150 * time.Millisecond
+
+// destino da caminhada automática
+type AlvoViagem struct {
+	Descricao string               // texto mostrado no status (ex: "saída")
+	Posicao   func() (Ponto, bool) // posição atual do alvo (pode mudar, ex: outro jogador)
+	Adjacente bool                 // para do lado do alvo em vez de em cima (alvo ocupa a célula)
+}
+
+// Começa a andar sozinho até o alvo, cancelando uma viagem anterior
+func (gc *GameClient) ViajarPara(alvo AlvoViagem) {
+	gc.CancelarViagem()
+
+	parar := make(chan struct{})
+	gc.mutex.Lock()
+	gc.pararViagem = parar
+	gc.mutex.Unlock()
+
+	gc.gameManager.DefinirStatus("Viajando até " + alvo.Descricao)
+	go gc.loopViagem(alvo, parar)
+}
+
+// Interrompe a caminhada automática, se houver uma em andamento
+func (gc *GameClient) CancelarViagem() bool {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+
+	if gc.pararViagem == nil {
+		return false
+	}
+	close(gc.pararViagem)
+	gc.pararViagem = nil
+	return true
+}
+
+// encerra a viagem por conta própria (chegou ou bloqueou) e mostra o motivo
+func (gc *GameClient) terminarViagem(parar chan struct{}, msg string) {
+	gc.mutex.Lock()
+	if gc.pararViagem != parar {
+		gc.mutex.Unlock()
+		return // já foi cancelada ou substituída por outra viagem
+	}
+	gc.pararViagem = nil
+	gc.mutex.Unlock()
+
+	gc.gameManager.DefinirStatus(msg)
+}
+
+// dá um passo por vez recalculando o caminho, já que o alvo e os outros jogadores andam
+func (gc *GameClient) loopViagem(alvo AlvoViagem, parar chan struct{}) {
+	ticker := time.NewTicker(intervaloViagem)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-parar:
+			return
+		case <-ticker.C:
+		}
+
+		destino, ok := alvo.Posicao()
+		if !ok {
+			gc.terminarViagem(parar, fmt.Sprintf("Alvo %s não existe mais", alvo.Descricao))
+			return
+		}
+		origem, ok := gc.gameManager.PosicaoLocal()
+		if !ok {
+			gc.terminarViagem(parar, "Jogador local não encontrado")
+			return
+		}
+
+		estado := gc.gameManager.ObterEstado()
+		ocupadas := PosicoesOcupadas(estado.Jogadores, gc.jogadorID)
+		delete(ocupadas, destino) // o alvo pode ser um jogador; ele não bloqueia o próprio caminho
+		caminho := BuscarCaminho(estado.Mapa, origem, destino, EvitandoOcupadas(CustoPadrao(estado.Mapa), ocupadas))
+
+		restantes := len(caminho)
+		if alvo.Adjacente {
+			restantes-- // o último passo seria em cima do alvo
+		}
+		switch {
+		case caminho == nil:
+			gc.terminarViagem(parar, fmt.Sprintf("Caminho até %s bloqueado", alvo.Descricao))
+			return
+		case restantes <= 0:
+			gc.terminarViagem(parar, fmt.Sprintf("Chegou em %s", alvo.Descricao))
+			return
+		}
+
+		// confere o passo com as regras locais antes de mandar pro servidor
+		proximo := caminho[0]
+		tecla, ok := TeclaPara(origem, proximo)
+		if !ok || !gc.gameManager.PodeMoverLocal(proximo) {
+			gc.terminarViagem(parar, fmt.Sprintf("Caminho até %s bloqueado", alvo.Descricao))
+			return
+		}
+		if err := gc.Mover(gc.jogadorID, tecla); err != nil {
+			gc.terminarViagem(parar, "Erro ao mover: "+err.Error())
+			return
+		}
+		gc.gameManager.DefinirStatus(fmt.Sprintf("Viajando até %s (%d passos)", alvo.Descricao, restantes-1))
+	}
+}
+
+// alvo que segue outro jogador pelo id
+func AlvoJogador(gm *GameManager, id, nome string) AlvoViagem {
+	return AlvoViagem{
+		Descricao: nome,
+		Adjacente: true,
+		Posicao: func() (Ponto, bool) {
+			jogador, existe := gm.ObterEstado().Jogadores[id]
+			if !existe {
+				return Ponto{}, false
+			}
+			return Ponto{jogador.PosX, jogador.PosY}, true
+		},
+	}
+}
+
+// alvo fixo numa célula do mapa
+func AlvoPonto(descricao string, p Ponto) AlvoViagem {
+	return AlvoViagem{
+		Descricao: descricao,
+		Posicao:   func() (Ponto, bool) { return p, true },
+	}
+}
+
+// procura a saída do nível no mapa
+func EncontrarSaida(mapa [][]Elemento) (Ponto, bool) {
+	for y, linha := range mapa {
+		for x, elem := range linha {
+			if elem.Simbolo == Saida.Simbolo {
+				return Ponto{x, y}, true
+			}
+		}
+	}
+	return Ponto{}, false
+}
+
+// escolhe o próximo jogador remoto (em ordem de nome) a cada chamada
+func (gc *GameClient) proximoJogadorAlvo() (AlvoViagem, bool) {
+	estado := gc.gameManager.ObterEstado()
+
+	var outros []*Jogador
+	for id, jogador := range estado.Jogadores {
+		if id != gc.jogadorID && jogador.Conectado {
+			outros = append(outros, jogador)
+		}
+	}
+	if len(outros) == 0 {
+		return AlvoViagem{}, false
+	}
+	sort.Slice(outros, func(i, j int) bool { return outros[i].Nome < outros[j].Nome })
+
+	gc.indiceAlvo = (gc.indiceAlvo + 1) % len(outros)
+	jogador := outros[gc.indiceAlvo]
+	return AlvoJogador(gc.gameManager, jogador.ID, jogador.Nome), true
+}
+
+// trata as teclas de viagem vindas da interface
+func (gc *GameClient) ProcessarEventoViagem(evento EventoTeclado) {
+	switch evento.Tipo {
+	case "viajar_jogador":
+		alvo, ok := gc.proximoJogadorAlvo()
+		if !ok {
+			gc.gameManager.DefinirStatus("Nenhum outro jogador conectado")
+			return
+		}
+		gc.ViajarPara(alvo)
+	case "viajar_saida":
+		saida, ok := EncontrarSaida(gc.gameManager.ObterEstado().Mapa)
+		if !ok {
+			gc.gameManager.DefinirStatus("Este mapa não tem saída")
+			return
+		}
+		gc.ViajarPara(AlvoPonto("saída", saida))
+	case "marcar":
+		pos, ok := gc.gameManager.PosicaoLocal()
+		if !ok {
+			return
+		}
+		gc.marca = &pos
+		gc.gameManager.DefinirStatus(fmt.Sprintf("Marca colocada em (%d, %d)", pos.X, pos.Y))
+	case "viajar_marca":
+		if gc.marca == nil {
+			gc.gameManager.DefinirStatus("Nenhuma marca colocada (use M)")
+			return
+		}
+		gc.ViajarPara(AlvoPonto("marca", *gc.marca))
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket (RFC 6455) só com o que o gateway precisa: mensagens de texto
+// pequenas, ping/pong e fechamento. Não tem extensões nem compressão.
+
+// GUID fixo do RFC 6455 usado no Sec-WebSocket-Accept
+const guidWebSocket = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maior mensagem aceita de um cliente (os pedidos do jogo são bem menores)
+const maxMensagemWebSocket = 1 << 20
+
+// opcodes dos quadros
+const (
+	wsContinuacao = 0x0
+	wsTexto       = 0x1
+	wsBinario     = 0x2
+	wsFechar      = 0x8
+	wsPing        = 0x9
+	wsPong        = 0xA
+)
+
+var ErrMensagemGrande = errors.New("websocket: mensagem grande demais")
+
+// conexão WebSocket vista como um net.Conn: Read devolve o conteúdo das
+// mensagens em sequência (o decoder JSON separa uma da outra) e cada Write
+// vira uma mensagem de texto
+type conexaoWebSocket struct {
+	net.Conn
+	leitor    *bufio.Reader
+	restante  int64 // bytes do quadro atual ainda não lidos
+	mascara   [4]byte
+	posMasc   int
+	mutexEsc  sync.Mutex // Write pode vir das respostas e das atualizações ao mesmo tempo
+	fecharUma sync.Once
+}
+
+// Responde o handshake e assume a conexão HTTP
+func aceitarWebSocket(w http.ResponseWriter, r *http.Request) (*conexaoWebSocket, error) {
+	chave := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || chave == "" ||
+		!cabecalhoContem(r.Header, "Connection", "upgrade") || !cabecalhoContem(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "esperava um pedido de WebSocket", http.StatusBadRequest)
+		return nil, errors.New("websocket: pedido sem upgrade")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "versão de WebSocket não suportada", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: versão não suportada")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "servidor não suporta WebSocket", http.StatusInternalServerError)
+		return nil, errors.New("websocket: resposta sem hijack")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	soma := sha1.Sum([]byte(chave + guidWebSocket))
+	resposta := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(soma[:]) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(resposta)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &conexaoWebSocket{Conn: conn, leitor: rw.Reader}, nil
+}
+
+// confere se um cabeçalho com lista separada por vírgula tem o valor (sem diferenciar maiúsculas)
+func cabecalhoContem(h http.Header, nome, valor string) bool {
+	for _, linha := range h.Values(nome) {
+		for _, item := range strings.Split(linha, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), valor) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Lê o conteúdo das mensagens de dados, respondendo ping e fechamento no caminho
+func (c *conexaoWebSocket) Read(p []byte) (int, error) {
+	for c.restante == 0 {
+		if err := c.lerCabecalho(); err != nil {
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > c.restante {
+		p = p[:c.restante]
+	}
+	n, err := c.leitor.Read(p)
+	for i := range n {
+		p[i] ^= c.mascara[c.posMasc%4]
+		c.posMasc++
+	}
+	c.restante -= int64(n)
+	return n, err
+}
+
+// lê o cabeçalho do próximo quadro; quadros de controle são tratados aqui mesmo
+func (c *conexaoWebSocket) lerCabecalho() error {
+	var cab [2]byte
+	if _, err := io.ReadFull(c.leitor, cab[:]); err != nil {
+		return err
+	}
+	opcode := cab[0] & 0x0F
+	mascarado := cab[1]&0x80 != 0
+	tamanho := int64(cab[1] & 0x7F)
+
+	switch tamanho {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.leitor, ext[:]); err != nil {
+			return err
+		}
+		tamanho = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.leitor, ext[:]); err != nil {
+			return err
+		}
+		tamanho = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if tamanho < 0 || tamanho > maxMensagemWebSocket {
+		c.Close()
+		return ErrMensagemGrande
+	}
+	if !mascarado {
+		c.Close()
+		return errors.New("websocket: quadro do cliente sem máscara")
+	}
+	if _, err := io.ReadFull(c.leitor, c.mascara[:]); err != nil {
+		return err
+	}
+	c.posMasc = 0
+
+	switch opcode {
+	case wsContinuacao, wsTexto, wsBinario:
+		c.restante = tamanho
+		return nil
+	}
+
+	// quadro de controle: lê tudo e responde
+	dados := make([]byte, tamanho)
+	if _, err := io.ReadFull(c.leitor, dados); err != nil {
+		return err
+	}
+	for i := range dados {
+		dados[i] ^= c.mascara[i%4]
+	}
+	switch opcode {
+	case wsPing:
+		return c.escreverQuadro(wsPong, dados)
+	case wsPong:
+		return nil
+	case wsFechar:
+		c.Close()
+		return io.EOF
+	}
+	return fmt.Errorf("websocket: opcode %d desconhecido", opcode)
+}
+
+// Manda p como uma mensagem de texto
+func (c *conexaoWebSocket) Write(p []byte) (int, error) {
+	if err := c.escreverQuadro(wsTexto, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// escreve um quadro inteiro de uma vez (o servidor não mascara)
+func (c *conexaoWebSocket) escreverQuadro(opcode byte, dados []byte) error {
+	quadro := make([]byte, 0, len(dados)+10)
+	quadro = append(quadro, 0x80|opcode) // FIN: cada mensagem vai num quadro só
+	switch n := len(dados); {
+	case n < 126:
+		quadro = append(quadro, byte(n))
+	case n <= 0xFFFF:
+		quadro = append(quadro, 126)
+		quadro = binary.BigEndian.AppendUint16(quadro, uint16(n))
+	default:
+		quadro = append(quadro, 127)
+		quadro = binary.BigEndian.AppendUint64(quadro, uint64(n))
+	}
+	quadro = append(quadro, dados...)
+
+	c.mutexEsc.Lock()
+	defer c.mutexEsc.Unlock()
+	_, err := c.Conn.Write(quadro)
+	return err
+}
+
+// Manda o quadro de fechamento (se der) e fecha a conexão
+func (c *conexaoWebSocket) Close() error {
+	err := net.ErrClosed
+	c.fecharUma.Do(func() {
+		c.Conn.SetWriteDeadline(time.Now().Add(time.Second)) // cliente que não lê não segura o fechamento
+		c.escreverQuadro(wsFechar, nil)
+		err = c.Conn.Close()
+	})
+	return err
+}